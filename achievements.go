@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Achievement is a milestone of the history, unlocked once its value reaches the goal
+type Achievement struct {
+	Name        string
+	Description string // Format of the description, given the goal
+	Goal        int
+	Value       func(progress Progress) int
+}
+
+// Achievements are the milestones of the history, in the order they are listed
+var Achievements = []Achievement{
+	{"First pomodoro", "Complete %d pomodoro", 1, func(p Progress) int { return p.Total }},
+	{"Centurion", "Complete %d pomodoros", 100, func(p Progress) int { return p.Total }},
+	{"Marathon", "Complete %d pomodoros in a day", 10, func(p Progress) int { return p.Busiest }},
+	{"Week streak", "Complete pomodoros %d days in a row", 7, func(p Progress) int { return p.Streak }},
+	{"Month streak", "Complete pomodoros %d days in a row", 30, func(p Progress) int { return p.Streak }},
+	{"Undisturbed", "Complete %d pomodoros in a day without any interruption", 4, func(p Progress) int { return p.Undisturbed }},
+}
+
+// Progress aggregates the completed work sessions of the history for the achievements
+type Progress struct {
+	Total       int // Completed pomodoros
+	Busiest     int // Most pomodoros completed in a day
+	Streak      int // Most consecutive days with completed pomodoros
+	Undisturbed int // Most pomodoros completed in a day without interruptions
+}
+
+// Unlocked returns whether the achievement is unlocked by the progress
+func (achievement Achievement) Unlocked(progress Progress) bool {
+	return achievement.Value(progress) >= achievement.Goal
+}
+
+// Describe returns the translated description of the achievement
+func (achievement Achievement) Describe() string {
+	return T(achievement.Description, achievement.Goal)
+}
+
+// MeasureProgress aggregates the sessions by day. A day is interrupted when
+// any of its work sessions was, whatever its result.
+func MeasureProgress(sessions []Session) Progress {
+	completed, interrupted := make(map[string]int), make(map[string]bool)
+	var progress Progress
+	for _, session := range sessions {
+		if session.Status != Work.String() {
+			continue
+		}
+		day := session.Start.Local().Format(time.DateOnly)
+		if len(session.Interruptions) > 0 {
+			interrupted[day] = true
+		}
+		if session.Result == Completed {
+			completed[day]++
+			progress.Total++
+		}
+	}
+
+	days := make([]string, 0, len(completed))
+	for day, count := range completed {
+		days = append(days, day)
+		progress.Busiest = max(progress.Busiest, count)
+		if !interrupted[day] {
+			progress.Undisturbed = max(progress.Undisturbed, count)
+		}
+	}
+	sort.Strings(days)
+	streak := 0
+	for i, day := range days {
+		if i > 0 && nextDay(days[i-1]) == day {
+			streak++
+		} else {
+			streak = 1
+		}
+		progress.Streak = max(progress.Streak, streak)
+	}
+	return progress
+}
+
+// nextDay returns the date following a YYYY-MM-DD date
+func nextDay(day string) string {
+	date, _ := time.Parse(time.DateOnly, day)
+	return date.AddDate(0, 0, 1).Format(time.DateOnly)
+}
+
+// NewAchievements returns the achievements unlocked by the progress after but not before
+func NewAchievements(before, after Progress) []Achievement {
+	var unlocked []Achievement
+	for _, achievement := range Achievements {
+		if achievement.Unlocked(after) && !achievement.Unlocked(before) {
+			unlocked = append(unlocked, achievement)
+		}
+	}
+	return unlocked
+}
+
+// CheckAchievements queues the notifications of the achievements unlocked by
+// the last session of the history
+func (state *PomodoroState) CheckAchievements() error {
+	sessions, err := state.History.Load()
+	if err != nil || len(sessions) == 0 {
+		return err
+	}
+	before, after := MeasureProgress(sessions[:len(sessions)-1]), MeasureProgress(sessions)
+	state.Unlocked = append(state.Unlocked, NewAchievements(before, after)...)
+	return nil
+}
+
+// ListAchievements writes every achievement, checked when unlocked and with
+// the progress towards it otherwise
+func ListAchievements(w io.Writer, progress Progress) error {
+	var builder strings.Builder
+	for _, achievement := range Achievements {
+		mark, status := " ", fmt.Sprintf("(%d/%d)", achievement.Value(progress), achievement.Goal)
+		if achievement.Unlocked(progress) {
+			mark, status = "✓", ""
+		}
+		line := fmt.Sprintf("%s %-15s %s %s", mark, T(achievement.Name), achievement.Describe(), status)
+		builder.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
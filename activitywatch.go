@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ActivityWatch reports the work and rest intervals as events of a bucket of
+// a local ActivityWatch server, one event per uninterrupted running interval
+type ActivityWatch struct {
+	URL    string
+	Bucket string
+
+	client  *http.Client
+	created bool   // Whether the bucket was created
+	start   *Event // Start of the running interval
+}
+
+// NewActivityWatch creates the integration reporting to the server at url,
+// in a bucket named after the host as ActivityWatch watchers do
+func NewActivityWatch(url string) *ActivityWatch {
+	hostname, _ := os.Hostname()
+	return &ActivityWatch{
+		URL:    strings.TrimSuffix(url, "/"),
+		Bucket: "aw-watcher-pomodoro_" + hostname,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name returns the name of the integration
+func (aw *ActivityWatch) Name() string {
+	return "activitywatch"
+}
+
+// Handle reports the running interval once it stops (paused, ended or the task
+// changed) and remembers the start of the next one
+func (aw *ActivityWatch) Handle(event Event) error {
+	var err error
+	if aw.start != nil {
+		err = aw.report(*aw.start, event.Time)
+		aw.start = nil
+	}
+	if event.Running(event.Status) && event.Name != EventWorkEnd && event.Name != EventRestEnd {
+		aw.start = &event
+	}
+	return err
+}
+
+// report inserts the event of an interval, creating the bucket when needed
+func (aw *ActivityWatch) report(start Event, end time.Time) error {
+	if !aw.created {
+		hostname, _ := os.Hostname()
+		bucket := map[string]any{
+			"client":   "polybar-pomo",
+			"type":     "app.pomodoro.status",
+			"hostname": hostname,
+		}
+		if err := aw.post("/api/0/buckets/"+aw.Bucket, bucket); err != nil {
+			return err
+		}
+		aw.created = true
+	}
+
+	data := map[string]any{"status": start.Status.String()}
+	if start.Task != "" {
+		data["task"] = start.Task
+	}
+	events := []map[string]any{{
+		"timestamp": start.Time.UTC().Format(time.RFC3339Nano),
+		"duration":  end.Sub(start.Time).Seconds(),
+		"data":      data,
+	}}
+	return aw.post("/api/0/buckets/"+aw.Bucket+"/events", events)
+}
+
+func (aw *ActivityWatch) post(path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	response, err := aw.client.Post(aw.URL+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// Creating an existing bucket answers 304 Not Modified
+	if response.StatusCode/100 != 2 && response.StatusCode != http.StatusNotModified {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", path, response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
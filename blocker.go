@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Markers of the block of the hosts file managed by the blocker
+const (
+	HostsBegin = "# BEGIN polybar-pomo blocker"
+	HostsEnd   = "# END polybar-pomo blocker"
+)
+
+// Blocker blocks the distracting websites while working: when a work period
+// starts running, it points the hosts at 0.0.0.0 in a marked block of the
+// hosts file and runs the On command (e.g. an external blocker CLI), and on
+// break, on exit or on the "unblock" command it removes the block and runs
+// the Off command. Pauses keep the websites blocked.
+type Blocker struct {
+	Hosts     []string // Hosts to block, e.g. reddit.com
+	HostsFile string   // Path of the hosts file, /etc/hosts by default
+	On, Off   string   // Shell commands enabling and lifting an external blocker
+
+	active  bool
+	started bool // Whether an event was handled, the first one lifting a block left by a previous run
+}
+
+// Name returns the name of the integration
+func (blocker *Blocker) Name() string {
+	return "blocker"
+}
+
+// Handle enables the blocker on a running work period, and lifts it on rest,
+// shutdown or when overridden until the end of the period
+func (blocker *Blocker) Handle(event Event) error {
+	lift := event.Status == Rest || event.Name == EventShutdown || event.Unblocked
+	defer func() { blocker.started = true }()
+	if event.Running(Work) && !event.Unblocked && !blocker.active {
+		blocker.active = true
+		return blocker.block()
+	} else if (blocker.active && lift) || !blocker.started {
+		blocker.active = false
+		return blocker.unblock()
+	}
+	return nil
+}
+
+func (blocker *Blocker) block() error {
+	var errs []error
+	if len(blocker.Hosts) > 0 {
+		var lines []string
+		for _, host := range blocker.Hosts {
+			lines = append(lines, "0.0.0.0 "+host, ":: "+host)
+		}
+		errs = append(errs, blocker.writeHosts(lines))
+	}
+	if blocker.On != "" {
+		errs = append(errs, runShell(blocker.On))
+	}
+	return errors.Join(errs...)
+}
+
+func (blocker *Blocker) unblock() error {
+	var errs []error
+	if len(blocker.Hosts) > 0 {
+		errs = append(errs, blocker.writeHosts(nil))
+	}
+	if blocker.Off != "" {
+		errs = append(errs, runShell(blocker.Off))
+	}
+	return errors.Join(errs...)
+}
+
+// writeHosts replaces the marked block of the hosts file with the given
+// lines, or removes it when there are none. The file is rewritten in place,
+// as it is often a mount point (e.g. in containers).
+func (blocker *Blocker) writeHosts(lines []string) error {
+	data, err := os.ReadFile(blocker.HostsFile)
+	if err != nil {
+		return err
+	}
+	content := RemoveHostsBlock(string(data))
+	if len(lines) > 0 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += HostsBegin + "\n" + strings.Join(lines, "\n") + "\n" + HostsEnd + "\n"
+	}
+	if content == string(data) {
+		return nil
+	}
+	if err := os.WriteFile(blocker.HostsFile, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("writing the hosts file (it must be writable by this user): %w", err)
+	}
+	return nil
+}
+
+// RemoveHostsBlock returns the content of a hosts file without the block of the blocker
+func RemoveHostsBlock(content string) string {
+	var kept []string
+	inside := false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		switch strings.TrimSpace(line) {
+		case HostsBegin:
+			inside = true
+			continue
+		case HostsEnd:
+			inside = false
+			continue
+		}
+		if !inside && line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "")
+}
+
+// runShell runs a shell command, with its output in the error
+func runShell(command string) error {
+	output, err := exec.Command("sh", "-c", command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", command, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestRemoveHostsBlock(t *testing.T) {
+	block := HostsBegin + "\n0.0.0.0 reddit.com\n:: reddit.com\n" + HostsEnd + "\n"
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", ""},
+		{"no block", "127.0.0.1 localhost\n::1 localhost\n", "127.0.0.1 localhost\n::1 localhost\n"},
+		{"block only", block, ""},
+		{"block at the end", "127.0.0.1 localhost\n" + block, "127.0.0.1 localhost\n"},
+		{"block in the middle", "127.0.0.1 localhost\n" + block + "::1 localhost\n", "127.0.0.1 localhost\n::1 localhost\n"},
+		{"indented markers", "127.0.0.1 localhost\n  " + HostsBegin + "\n0.0.0.0 reddit.com\n" + HostsEnd + "  \n", "127.0.0.1 localhost\n"},
+		{"no final newline", "127.0.0.1 localhost", "127.0.0.1 localhost"},
+		{"unterminated block", "127.0.0.1 localhost\n" + HostsBegin + "\n0.0.0.0 reddit.com\n", "127.0.0.1 localhost\n"},
+	}
+	for _, test := range tests {
+		if got := RemoveHostsBlock(test.content); got != test.want {
+			t.Errorf("%s: RemoveHostsBlock = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Example commands printing today's busy intervals, for -calendar-command
+const (
+	KhalCommand     = `khal list --format "{start-time} {end-time}" --day-format "" today today`
+	CalcurseCommand = `calcurse -Q --filter-type apt --format-apt "%(start:%H:%M) %(end:%H:%M)\n"`
+)
+
+// Busy pauses the running timer when a calendar event starts, remembering to
+// resume it once the event ends
+func (state *PomodoroState) Busy() {
+	if !state.Paused {
+		state.Pause()
+		state.CalendarPaused = true
+	}
+}
+
+// Free resumes the timer paused by Busy, unless it was resumed or paused by
+// hand since then
+func (state *PomodoroState) Free() {
+	if state.CalendarPaused && state.Paused {
+		state.Pause()
+	}
+	state.CalendarPaused = false
+}
+
+// Interval is a busy time range of the calendar
+type Interval struct {
+	Start, End time.Time
+}
+
+// ParseIntervals parses one busy interval per line, as a start and an end
+// that are either times of the given day ("09:00 10:30") or full dates
+// ("2024-05-02 09:00 2024-05-02 10:30"). Other lines are skipped, e.g. the
+// blank ones or the all-day events.
+func ParseIntervals(output []byte, day time.Time) []Interval {
+	var intervals []Interval
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var start, end time.Time
+		err := errors.New("expected 2 or 4 fields")
+		switch len(fields) {
+		case 2:
+			if start, err = parseClock(fields[0], day); err == nil {
+				end, err = parseClock(fields[1], day)
+			}
+		case 4:
+			if start, err = time.ParseInLocation("2006-01-02 15:04", fields[0]+" "+fields[1], day.Location()); err == nil {
+				end, err = time.ParseInLocation("2006-01-02 15:04", fields[2]+" "+fields[3], day.Location())
+			}
+		}
+		if err != nil {
+			slog.Debug("skipping calendar line", "line", scanner.Text(), "err", err)
+			continue
+		}
+		intervals = append(intervals, Interval{Start: start, End: end})
+	}
+	return intervals
+}
+
+// parseClock parses a HH:MM time of the given day
+func parseClock(value string, day time.Time) (time.Time, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, day.Location()), nil
+}
+
+// WatchCalendar runs the command every interval and sends the busy command
+// to the main loop when one of the intervals it prints starts, and free when
+// it ends. The command goes through sh, e.g. KhalCommand or CalcurseCommand.
+func WatchCalendar(command string, interval time.Duration, commands chan Command) {
+	busy := false
+	for ; ; time.Sleep(interval) {
+		output, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			slog.Warn("running the calendar command", "err", err)
+			continue
+		}
+		now := time.Now()
+		current := false
+		for _, interval := range ParseIntervals(output, now) {
+			current = current || (!now.Before(interval.Start) && now.Before(interval.End))
+		}
+		if current != busy {
+			busy = current
+			slog.Debug("calendar changed", "busy", busy)
+			if busy {
+				commands <- Command{Name: "busy"}
+			} else {
+				commands <- Command{Name: "free"}
+			}
+		}
+	}
+}
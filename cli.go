@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Subcommand is a command of the CLI, run instead of the daemon with its own flags
+type Subcommand struct {
+	Summary string
+	Run     func(args []string) error
+}
+
+// Subcommands returns the subcommands of the CLI by name. Besides the client
+// and history tools, every daemon command can be sent as a subcommand.
+func Subcommands() map[string]Subcommand {
+	subcommands := map[string]Subcommand{
+		"status":     {"Print the state of the running daemon once", Status},
+		"subscribe":  {"Print every update of the running daemon", Subscribe},
+		"repl":       {"Control the running daemon interactively", Repl},
+		"doctor":     {"Diagnose the setup and the running daemon", Doctor},
+		"completion": {"Print the completion script of a shell", Completion},
+		"stop":       {"Stop the background daemon", Stop},
+		"prompt":     {"Print a segment for shell prompts", Prompt},
+		"stats":      {"Print statistics of the history", Stats},
+		"report":     {"Render charts of the history as SVG or PNG", Report},
+		"export":     {"Export the history as iCalendar", Export},
+		"import":     {"Import the history of another pomodoro app", Import},
+		"config":     {"Check or write the config file", runConfig},
+	}
+	for _, name := range CommandNames {
+		name := name
+		subcommands[name] = Subcommand{
+			Summary: fmt.Sprintf("Send the %s command to the running daemon", name),
+			Run:     func(args []string) error { return Control(name, args) },
+		}
+	}
+	return subcommands
+}
+
+// runConfig runs the config subcommands on the file given with -config
+func runConfig(args []string) error {
+	return ConfigCommand(args, flag.CommandLine, flag.Lookup("config").Value.String())
+}
+
+// SubcommandNames returns the names of the subcommands, including daemon and
+// help, in a stable order
+func SubcommandNames() []string {
+	names := []string{"daemon", "help"}
+	for name := range Subcommands() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Usage prints the subcommands and the flags of the daemon
+func Usage() {
+	output := flag.CommandLine.Output()
+	fmt.Fprintln(output, "Usage:")
+	fmt.Fprintln(output, "  polybar-pomo [daemon] [flags]        Run the timer")
+	fmt.Fprintln(output, "  polybar-pomo <subcommand> [flags]    Run a subcommand, see polybar-pomo help <subcommand>")
+	fmt.Fprintln(output, "\nSubcommands:")
+
+	subcommands := Subcommands()
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(output, "  %-12s %s\n", name, subcommands[name].Summary)
+	}
+	fmt.Fprintln(output, "\nDaemon flags, also read from the POLYBAR_POMO_<FLAG> environment variables and the config file:")
+	flag.PrintDefaults()
+}
+
+// Help prints the usage, or the one of a subcommand
+func Help(args []string) error {
+	if len(args) == 0 || args[0] == "daemon" {
+		Usage()
+		return nil
+	}
+	subcommand, ok := Subcommands()[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+	return subcommand.Run([]string{"-h"})
+}
+
+// Status prints the state of the running daemon once, for bars that poll on an interval
+func Status(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	formatFlag := flags.String("format", "polybar", "Output format: polybar, waybar, json, conky, genmon, i3rs or eww")
+	flags.Parse(args)
+
+	if _, ok := Renderers[*formatFlag]; !ok {
+		return fmt.Errorf("unknown output format %q", *formatFlag)
+	}
+	return Once(*formatFlag)
+}
+
+// Control sends a command with its arguments to the running daemon
+func Control(name string, args []string) error {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	addrFlag := flags.String("addr", "unix://"+SocketPath, "Daemon address (e.g. tcp://127.0.0.1:7777)")
+	tokenFlag := flags.String("token", "", "Shared token of the daemon network listener")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: polybar-pomo %s [flags] [args]\n", name)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	message := Command{Name: name, Args: flags.Args()}.String()
+	if *tokenFlag != "" {
+		message = *tokenFlag + " " + message
+	}
+	return SendCommand(*addrFlag, message)
+}
+
+// SendCommand writes a message to the daemon listening on addr, returning the
+// error it answers with, if any
+func SendCommand(addr, message string) error {
+	replies, err := Request(addr, message)
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if reason, failed := strings.CutPrefix(reply, "error: "); failed {
+			return errors.New(reason)
+		}
+	}
+	return nil
+}
+
+// Request writes a message to the daemon listening on addr, returning the
+// lines it replies with before closing the connection or timing out
+func Request(addr, message string) ([]string, error) {
+	conn, err := Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(PeerTimeout))
+	if _, err := conn.Write([]byte(message + "\n")); err != nil {
+		return nil, err
+	}
+	var replies []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		replies = append(replies, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return replies, err
+	}
+	return replies, nil
+}
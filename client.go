@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Subscribe connects to a running daemon and prints every update it streams,
+// reconnecting whenever the daemon goes away
+func Subscribe(args []string) error {
+	flags := flag.NewFlagSet("subscribe", flag.ExitOnError)
+	addrFlag := flags.String("addr", "unix://"+SocketPath, "Daemon address (e.g. tcp://127.0.0.1:7777)")
+	tokenFlag := flags.String("token", "", "Shared token of the daemon network listener")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: polybar-pomo subscribe [flags] [polybar|waybar|json]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	format := "polybar"
+	if flags.NArg() > 0 {
+		format = flags.Arg(0)
+	}
+	if _, ok := Renderers[format]; !ok {
+		return fmt.Errorf("unknown output format %q", format)
+	}
+
+	message := "subscribe " + format
+	if *tokenFlag != "" {
+		message = *tokenFlag + " " + message
+	}
+
+	for {
+		if err := Stream(*addrFlag, message, func(line string) { fmt.Println(line) }); err != nil {
+			slog.Warn("lost connection to daemon", "addr", *addrFlag, "err", err)
+		}
+		time.Sleep(FollowRetry)
+	}
+}
+
+// Stream sends the message to the daemon and calls handle for every line it
+// answers with, until the connection is closed
+func Stream(addr, message string, handle func(line string)) error {
+	conn, err := Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(message + "\n")); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		handle(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection to %s closed", addr)
+}
+
+// Once prints the state of the running daemon rendered in the format, or
+// else the one mirrored in the status files, for bars that poll on an interval
+func Once(format string) error {
+	conn, err := Dial("unix://" + SocketPath)
+	if err == nil {
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(PeerTimeout))
+		if _, err := conn.Write([]byte("subscribe " + format + "\n")); err != nil {
+			return err
+		}
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("reading state: %w", err)
+		}
+		fmt.Print(line)
+		return nil
+	}
+
+	// The daemon may only be reachable through its status files (e.g. in a
+	// container), as long as it keeps touching them
+	name := StatusFileNames[format]
+	if name == "" {
+		return fmt.Errorf("daemon unreachable: %w", err)
+	}
+	path := filepath.Join(StatusDir(), name)
+	if info, statErr := os.Stat(path); statErr != nil || time.Since(info.ModTime()) > StatusStale {
+		return fmt.Errorf("daemon unreachable: %w", err)
+	}
+	data, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return fmt.Errorf("daemon unreachable: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Color scheme sources of -colors-from
+const (
+	ColorsPywal      = "pywal"      // Colours generated by pywal, from its cache
+	ColorsXresources = "xresources" // Colours of the X resources, from xrdb
+)
+
+// SchemeClasses are the colours of a scheme given to the classes by default:
+// red, green and yellow, and bright red for the end of a period
+var SchemeClasses = map[string]string{"work": "color1", "rest": "color2", "paused": "color3", "ending": "color9"}
+
+// ColorScheme maps the colour names of a terminal scheme (color0 to color15,
+// foreground and background) to their values
+type ColorScheme map[string]string
+
+// LoadColorScheme reads the colour scheme of a source
+func LoadColorScheme(source string) (ColorScheme, error) {
+	switch source {
+	case ColorsPywal:
+		return loadPywal()
+	case ColorsXresources:
+		return loadXresources()
+	}
+	return nil, fmt.Errorf("unknown colour source %q (expected %s or %s)", source, ColorsPywal, ColorsXresources)
+}
+
+// loadPywal reads the colors.json file pywal writes in its cache
+func loadPywal() (ColorScheme, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "wal", "colors.json"))
+	if err != nil {
+		return nil, err
+	}
+	var wal struct {
+		Special map[string]string `json:"special"`
+		Colors  map[string]string `json:"colors"`
+	}
+	if err := json.Unmarshal(data, &wal); err != nil {
+		return nil, fmt.Errorf("parsing pywal colours: %w", err)
+	}
+	return ColorScheme(overlay(wal.Colors, wal.Special)), nil
+}
+
+// loadXresources reads the global colours of the X resources, e.g. "*.color1: #cc6666"
+func loadXresources() (ColorScheme, error) {
+	output, err := exec.Command("xrdb", "-query").Output()
+	if err != nil {
+		return nil, fmt.Errorf("querying xrdb: %w", err)
+	}
+	scheme := make(ColorScheme)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		name := strings.TrimPrefix(strings.TrimPrefix(key, "*"), ".")
+		if found && !strings.ContainsAny(name, ".*") {
+			scheme[name] = strings.TrimSpace(value)
+		}
+	}
+	return scheme, scanner.Err()
+}
+
+// Classes returns the colours of the classes per SchemeClasses
+func (scheme ColorScheme) Classes() map[string]string {
+	return scheme.Resolve(SchemeClasses)
+}
+
+// Resolve replaces the colour names of the scheme in the values of a mapping
+// (e.g. work=color5) by their values, keeping the others
+func (scheme ColorScheme) Resolve(colors map[string]string) map[string]string {
+	resolved := make(map[string]string, len(colors))
+	for class, color := range colors {
+		if value, ok := scheme[color]; ok {
+			resolved[class] = value
+		} else if !isSchemeName(color) {
+			resolved[class] = color
+		}
+	}
+	return resolved
+}
+
+// isSchemeName returns whether a colour is one of the names of the schemes,
+// left out when the scheme doesn't have it
+func isSchemeName(color string) bool {
+	if number, found := strings.CutPrefix(color, "color"); found {
+		_, err := strconv.Atoi(number)
+		return err == nil
+	}
+	return color == "foreground" || color == "background"
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// ClientFlags are the flags shared by the subcommands talking to a running daemon
+var ClientFlags = []string{"-addr", "-token"}
+
+// SubcommandArgs lists the subcommands along with the completion candidates of their arguments
+var SubcommandArgs = map[string][]string{
+	"subscribe":  append([]string{"polybar", "waybar", "json", "conky", "genmon", "i3rs", "eww"}, ClientFlags...),
+	"repl":       ClientFlags,
+	"doctor":     ClientFlags,
+	"completion": {"bash", "zsh", "fish"},
+	"prompt":     {"-file", "-format"},
+	"stop":       {},
+	"stats":      {"-file", "-top", "-heatmap", "-weeks", "-hours", "-json", "-achievements", "-tag", "-from", "-to"},
+	"export":     {"-file", "-format", "-o"},
+	"report":     {"-file", "-chart", "-days", "-weeks", "-tag", "-from", "-to"},
+	"import":     {"-from", "-file", "-dry-run", "gnome-pomodoro", "csv"},
+	"config":     {"validate", "init", "-force", "-print"},
+	"status":     {"-format"},
+	"help":       {},
+	"theme":      append(ThemeNames(), ClientFlags...),
+}
+
+// Completion prints a completion script for the given shell
+func Completion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: polybar-pomo completion bash|zsh|fish")
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion())
+	case "zsh":
+		fmt.Print(zshCompletion())
+	case "fish":
+		fmt.Print(fishCompletion())
+	default:
+		return fmt.Errorf("unsupported shell %q", args[0])
+	}
+	return nil
+}
+
+// subcommandArgs returns the completion candidates of the arguments of a
+// subcommand, the client flags for the daemon commands
+func subcommandArgs(name string) []string {
+	if name == "daemon" {
+		return rootFlags()
+	}
+	if args, ok := SubcommandArgs[name]; ok {
+		return args
+	}
+	return ClientFlags
+}
+
+// rootFlags returns the daemon flags in their single dash form
+func rootFlags() []string {
+	var flags []string
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, "-"+f.Name)
+	})
+	return flags
+}
+
+func bashCompletion() string {
+	var cases strings.Builder
+	for _, name := range SubcommandNames() {
+		fmt.Fprintf(&cases, "        %s) words=%q ;;\n", name, strings.Join(subcommandArgs(name), " "))
+	}
+
+	return fmt.Sprintf(`# bash completion for polybar-pomo
+_polybar_pomo() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" words=%q word
+    for word in "${COMP_WORDS[@]:1:COMP_CWORD-1}"; do
+        case "$word" in
+%s        esac
+    done
+    COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -F _polybar_pomo polybar-pomo
+`, strings.Join(append(SubcommandNames(), rootFlags()...), " "), cases.String())
+}
+
+func zshCompletion() string {
+	var cases strings.Builder
+	for _, name := range SubcommandNames() {
+		fmt.Fprintf(&cases, "      %s) candidates=(%s) ;;\n", name, strings.Join(subcommandArgs(name), " "))
+	}
+
+	return fmt.Sprintf(`#compdef polybar-pomo
+# zsh completion for polybar-pomo
+_polybar_pomo() {
+  local -a candidates
+  candidates=(%s)
+  local word
+  for word in ${words[2,CURRENT-1]}; do
+    case $word in
+%s    esac
+  done
+  compadd -- $candidates
+}
+compdef _polybar_pomo polybar-pomo
+`, strings.Join(append(SubcommandNames(), rootFlags()...), " "), cases.String())
+}
+
+func fishCompletion() string {
+	var script strings.Builder
+	script.WriteString("# fish completion for polybar-pomo\n")
+	script.WriteString("complete -c polybar-pomo -f\n")
+
+	names := strings.Join(SubcommandNames(), " ")
+	fmt.Fprintf(&script, "complete -c polybar-pomo -n 'not __fish_seen_subcommand_from %s' -a '%s'\n", names, names)
+	flag.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(&script, "complete -c polybar-pomo -n 'not __fish_seen_subcommand_from %s' -o %s -d %s\n", names, f.Name, fishQuote(f.Usage))
+	})
+
+	for _, name := range SubcommandNames() {
+		for _, arg := range subcommandArgs(name) {
+			if option, isFlag := strings.CutPrefix(arg, "-"); isFlag {
+				fmt.Fprintf(&script, "complete -c polybar-pomo -n '__fish_seen_subcommand_from %s' -o %s\n", name, option)
+			} else {
+				fmt.Fprintf(&script, "complete -c polybar-pomo -n '__fish_seen_subcommand_from %s' -a %s\n", name, arg)
+			}
+		}
+	}
+	return script.String()
+}
+
+// fishQuote single-quotes s for fish, where double quotes would expand variables
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
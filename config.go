@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultConfigPath returns the path of the config file, under XDG_CONFIG_HOME when set
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "polybar-pomo", "config")
+}
+
+// EnvPrefix starts the names of the environment variables setting the flags
+const EnvPrefix = "POLYBAR_POMO_"
+
+// EnvName returns the environment variable setting a flag, e.g.
+// POLYBAR_POMO_WORK_RAMP for -work-ramp
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// LoadEnv applies the POLYBAR_POMO_* environment variables to the flags that
+// were not given on the command line, and returns the names of the flags it
+// set, for them to take precedence over the config file
+func LoadEnv(flags *flag.FlagSet) (map[string]bool, error) {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	fromEnv := make(map[string]bool)
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(EnvName(f.Name))
+		if !ok || set[f.Name] || err != nil {
+			return
+		}
+		if setErr := setFlag(f, value); setErr != nil {
+			err = fmt.Errorf("%s: invalid value for %s: %v", EnvName(f.Name), f.Name, setErr)
+		}
+		fromEnv[f.Name] = true
+	})
+	return fromEnv, err
+}
+
+// LoadConfig reads the "key = value" lines of the config file, where every key
+// is the name of a flag, and applies them to the flags that were not given on
+// the command line nor are in pinned. Blank lines and lines starting with #
+// are ignored.
+func LoadConfig(flags *flag.FlagSet, path string, pinned map[string]bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name := range pinned {
+		set[name] = true
+	}
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		key, value, skip, err := parseConfigLine(scanner.Text())
+		if skip {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+
+		if flags.Lookup(key) == nil {
+			return fmt.Errorf("%s:%d: unknown key %q", path, lineNumber, key)
+		}
+		if set[key] {
+			continue
+		}
+		if err := setFlag(flags.Lookup(key), value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value for %s: %v", path, lineNumber, key, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseConfigLine splits a line of the config file into its key and value,
+// unquoting the value. Blank lines and comments are skipped.
+func parseConfigLine(line string) (key, value string, skip bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", true, nil
+	}
+	key, value, found := strings.Cut(line, "=")
+	if !found {
+		return "", "", false, errors.New("expected key = value")
+	}
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	return key, value, false, nil
+}
+
+// ReloadableKeys are the settings applied again when the config file is
+// reloaded, the others only being read at startup
+var ReloadableKeys = []string{
+	"w", "r", "overtime", "auto-start-work", "auto-start-rest", "min-work", "max-extension", "min-remaining",
+	"views", "width", "minutes", "icon-position", "separator", "text", "paused-display",
+	"work-ramp", "rest-ramp", "markup", "markup-colors", "labels", "theme", "colors-from",
+	"reminders", "quiet-hours", "profile-schedule", "goal",
+}
+
+// ReloadConfig reads the config file again and sets the reloadable flags to
+// its values, or back to their defaults when removed from the file. The flags
+// in pinned, given on the command line, keep their value. On error, the flags
+// are left unchanged.
+func ReloadConfig(flags *flag.FlagSet, path string, pinned map[string]bool) error {
+	file := flag.NewFlagSet(path, flag.ContinueOnError)
+	flags.VisitAll(func(f *flag.Flag) { file.String(f.Name, f.DefValue, "") })
+	if err := LoadConfig(file, path, nil); err != nil {
+		return err
+	}
+
+	previous := make(map[string]string)
+	for _, key := range ReloadableKeys {
+		if pinned[key] {
+			continue
+		}
+		previous[key] = flags.Lookup(key).Value.String()
+		if err := setFlag(flags.Lookup(key), file.Lookup(key).Value.String()); err != nil {
+			for key, value := range previous {
+				setFlag(flags.Lookup(key), value)
+			}
+			return fmt.Errorf("%s: invalid value for %s: %v", path, key, err)
+		}
+	}
+	return nil
+}
+
+// setFlag sets the value of a flag, accepting the zero duration that the
+// optional duration flags default to, which can't be given on the command line
+func setFlag(f *flag.Flag, value string) error {
+	if duration, ok := f.Value.(*DurationFlag); ok && value == "0s" && f.DefValue == "0s" {
+		*duration = 0
+		return nil
+	}
+	return f.Value.Set(value)
+}
+
+// ParseMapping parses a comma-separated list of key=value pairs, as used by
+// the flags mapping task labels to the settings of an integration
+func ParseMapping(list string) map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if found {
+			mapping[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return mapping
+}
+
+// ConfigChecks validate the values whose flag type accepts more than the
+// daemon does, by key
+var ConfigChecks = map[string]func(value string) error{
+	"colors-from":       optional(oneOf(ColorsPywal, ColorsXresources)),
+	"focus-wm":          oneOf("auto", "sway", "wmctrl"),
+	"break-wm":          oneOf("auto", "sway", "i3", "hyprland", "wmctrl"),
+	"markup":            oneOf(MarkupNone, MarkupPolybar, MarkupPango, MarkupConky),
+	"paused-display":    oneOf(PausedFrozen, PausedBlink, PausedCounter, PausedHidden),
+	"icon-position":     oneOf("before", "after"),
+	"resume":            oneOf(ResumeRestore, ResumeAbort, ResumeOff),
+	"log-level":         checkLogLevel,
+	"format":            checkFormat,
+	"initial":           func(value string) error { _, err := ParseStatus(value); return err },
+	"views":             func(value string) error { _, err := ParseViews(value); return err },
+	"listen-permission": func(value string) error { _, err := ParsePermission(value); return err },
+	"socket-mode":       func(value string) error { _, err := ParseSocketMode(value); return err },
+	"socket-group":      optional(func(value string) error { _, err := LookupGroup(value); return err }),
+	"allow-uids":        optional(func(value string) error { _, err := ParseUIDs(value); return err }),
+	"reminders":         optional(func(value string) error { _, err := ParseReminders(value); return err }),
+	"quiet-hours":       optional(func(value string) error { _, err := ParseQuietHours(value); return err }),
+	"profile-schedule":  optional(func(value string) error { _, err := ParseProfileSchedule(value); return err }),
+}
+
+// oneOf returns a check accepting the given values only
+func oneOf(values ...string) func(string) error {
+	return func(value string) error {
+		for _, valid := range values {
+			if value == valid {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown value %q (expected %s)", value, strings.Join(values, ", "))
+	}
+}
+
+// optional returns a check accepting an empty value, which disables the setting
+func optional(check func(string) error) func(string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		return check(value)
+	}
+}
+
+// checkLogLevel accepts the names of the log levels, in any case
+func checkLogLevel(value string) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+		return fmt.Errorf("unknown log level %q", value)
+	}
+	return nil
+}
+
+// checkFormat accepts the names of the renderers
+func checkFormat(value string) error {
+	if _, ok := Renderers[value]; !ok && value != "none" {
+		return fmt.Errorf("unknown output format %q", value)
+	}
+	return nil
+}
+
+// ConfigCommand runs the config subcommands: validate checks every line of
+// the config file, init writes a commented config with the default values
+func ConfigCommand(args []string, settings *flag.FlagSet, path string) error {
+	if len(args) == 0 {
+		return errors.New("usage: polybar-pomo config validate|init")
+	}
+	switch args[0] {
+	case "validate":
+		return ValidateConfig(args[1:], settings, path)
+	case "init":
+		return InitConfig(args[1:], settings, path)
+	}
+	return fmt.Errorf("unknown config subcommand %q (expected validate or init)", args[0])
+}
+
+// ValidateConfig prints the errors of every line of the config file, instead
+// of stopping at the first one like the daemon does
+func ValidateConfig(args []string, settings *flag.FlagSet, path string) error {
+	flags := flag.NewFlagSet("config validate", flag.ExitOnError)
+	flags.Parse(args)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var errs int
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		key, value, skip, err := parseConfigLine(scanner.Text())
+		if skip {
+			continue
+		}
+		if err == nil && settings.Lookup(key) == nil {
+			err = fmt.Errorf("unknown key %q", key)
+		} else if err == nil {
+			if err = setFlag(settings.Lookup(key), value); err != nil {
+				err = fmt.Errorf("invalid value for %s: %v", key, err)
+			} else if check, ok := ConfigChecks[key]; ok {
+				if err = check(value); err != nil {
+					err = fmt.Errorf("invalid value for %s: %v", key, err)
+				}
+			}
+		}
+		if err != nil {
+			fmt.Printf("%s:%d: %v\n", path, lineNumber, err)
+			errs++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d invalid lines in %s", errs, path)
+	}
+	fmt.Printf("%s: ok\n", path)
+	return nil
+}
+
+// InitConfig writes a config file listing every setting with its description
+// and default value, commented out
+func InitConfig(args []string, settings *flag.FlagSet, path string) error {
+	flags := flag.NewFlagSet("config init", flag.ExitOnError)
+	forceFlag := flags.Bool("force", false, "Overwrite an existing config file")
+	printFlag := flags.Bool("print", false, "Print the config on stdout instead of writing it")
+	flags.Parse(args)
+
+	var config strings.Builder
+	config.WriteString("# polybar-pomo config: one 'flag = value' per line, overridden by the command line\n")
+	settings.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		value := f.DefValue
+		if value != strings.TrimSpace(value) || strings.ContainsAny(value, "\"#") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&config, "\n# %s\n# %s = %s\n", f.Usage, f.Name, value)
+	})
+
+	if *printFlag {
+		fmt.Print(config.String())
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil && !*forceFlag {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(config.String()), 0o644); err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
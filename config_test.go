@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	config := "cli-key = config\nenv-key = config\nconfig-key = config\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvName("cli-key"), "env")
+	t.Setenv(EnvName("env-key"), "env")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	values := make(map[string]*string)
+	for _, name := range []string{"cli-key", "env-key", "config-key", "default-key"} {
+		values[name] = flags.String(name, "default", "")
+	}
+	if err := flags.Parse([]string{"-cli-key", "cli"}); err != nil {
+		t.Fatal(err)
+	}
+	pinned, err := LoadEnv(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"env-key": true}; !reflect.DeepEqual(pinned, want) {
+		t.Errorf("LoadEnv set %v, want %v", pinned, want)
+	}
+	if err := LoadConfig(flags, path, pinned); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"cli-key", "cli"},
+		{"env-key", "env"},
+		{"config-key", "config"},
+		{"default-key", "default"},
+	}
+	for _, test := range tests {
+		if got := *values[test.name]; got != test.want {
+			t.Errorf("%s = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DaemonEnv is set in the environment of the background process started by
+// -daemon, so it doesn't start another one. Unlike POLYBAR_POMO_DAEMON, it
+// doesn't match a flag.
+const DaemonEnv = "POLYBAR_POMO_DAEMONIZED"
+
+// StopTimeout is how long stop waits for the daemon to exit
+const StopTimeout = 5 * time.Second
+
+// PIDPath returns the path of the PID file of the background daemon
+func PIDPath() string {
+	return filepath.Join(StatusDir(), "polybar-pomo.pid")
+}
+
+// Daemonize starts the daemon again in the background, in a new session and
+// detached from the terminal, with the same arguments. Logs go to logFile, or
+// to daemon.log in the data directory when it is empty.
+func Daemonize(logFile string) error {
+	if pid, err := ReadPID(); err == nil && processRunning(pid) {
+		return fmt.Errorf("already running with PID %d", pid)
+	}
+
+	// The daemon subcommand is dropped, as it would follow the injected flags
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "daemon" {
+		args = args[1:]
+	}
+	if logFile == "" {
+		if err := os.MkdirAll(DataDir(), 0o700); err != nil {
+			return err
+		}
+		args = append([]string{"-log-file", filepath.Join(DataDir(), "daemon.log")}, args...)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer devNull.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), DaemonEnv+"=1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = devNull, devNull, devNull
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	fmt.Println(cmd.Process.Pid)
+	return cmd.Process.Release()
+}
+
+// WritePID writes the PID of the current process to the PID file
+func WritePID() error {
+	if err := MakeStatusDir(StatusDir()); err != nil {
+		return err
+	}
+	return writeFileAtomic(PIDPath(), strconv.Itoa(os.Getpid()))
+}
+
+// ReadPID reads the PID of the background daemon
+func ReadPID() (int, error) {
+	data, err := os.ReadFile(PIDPath())
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// processRunning returns whether a process with the PID exists
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Stop terminates the background daemon and waits for it to exit
+func Stop(args []string) error {
+	flags := flag.NewFlagSet("stop", flag.ExitOnError)
+	flags.Parse(args)
+
+	pid, err := ReadPID()
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("no daemon running in the background")
+	} else if err != nil {
+		return err
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); errors.Is(err, syscall.ESRCH) {
+		os.Remove(PIDPath())
+		return fmt.Errorf("daemon with PID %d is not running anymore", pid)
+	} else if err != nil {
+		return err
+	}
+
+	for deadline := time.Now().Add(StopTimeout); processRunning(pid); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("daemon with PID %d didn't exit after %s", pid, StopTimeout)
+		}
+	}
+	return nil
+}
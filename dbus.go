@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// D-Bus message types
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+	dbusSignal       = 4
+)
+
+// D-Bus header field codes
+const (
+	dbusFieldPath        = 1
+	dbusFieldInterface   = 2
+	dbusFieldMember      = 3
+	dbusFieldErrorName   = 4
+	dbusFieldReplySerial = 5
+	dbusFieldDestination = 6
+	dbusFieldSender      = 7
+	dbusFieldSignature   = 8
+)
+
+// dbusPath, dbusSignature and dbusVariant mark the values encoded as the
+// object path, signature and variant D-Bus types
+type (
+	dbusPath      string
+	dbusSignature string
+	dbusVariant   struct{ Value any }
+)
+
+// dbusField is a field of the message header
+type dbusField struct {
+	Code  byte
+	Value any
+}
+
+// DBusMessage is a D-Bus message. The body only supports the types needed
+// by the gnome-pomodoro shim: strings, booleans, doubles, 32-bit unsigned
+// integers, string arrays and a{sv} dictionaries.
+type DBusMessage struct {
+	Type        byte
+	Serial      uint32
+	ReplySerial uint32
+	Path        string
+	Interface   string
+	Member      string
+	ErrorName   string
+	Destination string
+	Sender      string
+	Body        []any
+}
+
+// DBusConn is a connection to a message bus
+type DBusConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex // Serializes the writes and the serial numbers
+	serial uint32
+}
+
+// SessionBusAddress returns the path of the session bus socket, from
+// DBUS_SESSION_BUS_ADDRESS, where abstract sockets start with @
+func SessionBusAddress() (string, error) {
+	address := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if address == "" {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			return filepath.Join(dir, "bus"), nil
+		}
+		return "", errors.New("DBUS_SESSION_BUS_ADDRESS is not set")
+	}
+	for _, transport := range strings.Split(address, ";") {
+		options, found := strings.CutPrefix(transport, "unix:")
+		if !found {
+			continue
+		}
+		for _, option := range strings.Split(options, ",") {
+			if path, found := strings.CutPrefix(option, "path="); found {
+				return path, nil
+			} else if name, found := strings.CutPrefix(option, "abstract="); found {
+				return "@" + name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unsupported session bus address %q", address)
+}
+
+// DialSessionBus connects and authenticates to the session bus, and says Hello
+func DialSessionBus() (*DBusConn, error) {
+	address, err := SessionBusAddress()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", address, PeerTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	bus := &DBusConn{conn: conn, reader: bufio.NewReader(conn)}
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reply, err := bus.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(reply, "OK ") {
+		conn.Close()
+		return nil, fmt.Errorf("authenticating to the session bus: %q %v", strings.TrimSpace(reply), err)
+	}
+	if _, err := conn.Write([]byte("BEGIN\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if _, err := bus.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return bus, nil
+}
+
+// Close closes the connection
+func (bus *DBusConn) Close() error {
+	return bus.conn.Close()
+}
+
+// Send writes the message, assigning it the next serial number
+func (bus *DBusConn) Send(msg *DBusMessage) (uint32, error) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.serial++
+	msg.Serial = bus.serial
+	_, err := bus.conn.Write(msg.encode())
+	return msg.Serial, err
+}
+
+// Call sends a method call and waits for its reply, dropping the messages
+// received meanwhile. It is only meant to be used before serving requests.
+func (bus *DBusConn) Call(destination, path, iface, member string, args ...any) (*DBusMessage, error) {
+	serial, err := bus.Send(&DBusMessage{
+		Type: dbusMethodCall, Destination: destination, Path: path, Interface: iface, Member: member, Body: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := bus.Receive()
+		if err != nil {
+			return nil, err
+		}
+		if msg.ReplySerial != serial {
+			continue
+		}
+		if msg.Type == dbusError {
+			return nil, fmt.Errorf("%s: %s %v", member, msg.ErrorName, msg.Body)
+		}
+		return msg, nil
+	}
+}
+
+// Reply answers the method call with the given values
+func (bus *DBusConn) Reply(call *DBusMessage, values ...any) error {
+	_, err := bus.Send(&DBusMessage{Type: dbusMethodReturn, ReplySerial: call.Serial, Destination: call.Sender, Body: values})
+	return err
+}
+
+// ReplyError answers the method call with an error
+func (bus *DBusConn) ReplyError(call *DBusMessage, name, message string) error {
+	_, err := bus.Send(&DBusMessage{
+		Type: dbusError, ReplySerial: call.Serial, Destination: call.Sender, ErrorName: name, Body: []any{message},
+	})
+	return err
+}
+
+// Emit broadcasts a signal
+func (bus *DBusConn) Emit(path, iface, member string, values ...any) error {
+	_, err := bus.Send(&DBusMessage{Type: dbusSignal, Path: path, Interface: iface, Member: member, Body: values})
+	return err
+}
+
+// Receive reads the next message
+func (bus *DBusConn) Receive() (*DBusMessage, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(bus.reader, fixed); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if fixed[0] == 'B' {
+		order = binary.BigEndian
+	}
+	headerLength := (16 + int(order.Uint32(fixed[12:])) + 7) / 8 * 8
+	length := headerLength + int(order.Uint32(fixed[4:]))
+	if length > 1<<20 {
+		return nil, fmt.Errorf("message too long (%d bytes)", length)
+	}
+
+	data := append(fixed, make([]byte, length-16)...)
+	if _, err := io.ReadFull(bus.reader, data[16:]); err != nil {
+		return nil, err
+	}
+	return decodeDBusMessage(data, order, headerLength)
+}
+
+// decodeDBusMessage decodes the header fields and the body of a message, the
+// body as far as its types are supported
+func decodeDBusMessage(data []byte, order binary.ByteOrder, headerLength int) (*DBusMessage, error) {
+	msg := &DBusMessage{Type: data[1], Serial: order.Uint32(data[8:])}
+	decoder := &dbusDecoder{data: data[:headerLength], order: order, pos: 16}
+	var signature string
+	for decoder.align(8); decoder.pos < headerLength && decoder.err == nil; decoder.align(8) {
+		code := decoder.byte()
+		value := decoder.value('v')
+		switch v := value.(type) {
+		case string:
+			switch code {
+			case dbusFieldPath:
+				msg.Path = v
+			case dbusFieldInterface:
+				msg.Interface = v
+			case dbusFieldMember:
+				msg.Member = v
+			case dbusFieldErrorName:
+				msg.ErrorName = v
+			case dbusFieldDestination:
+				msg.Destination = v
+			case dbusFieldSender:
+				msg.Sender = v
+			case dbusFieldSignature:
+				signature = v
+			}
+		case uint32:
+			if code == dbusFieldReplySerial {
+				msg.ReplySerial = v
+			}
+		}
+	}
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+
+	body := &dbusDecoder{data: data[headerLength:], order: order}
+	for i := 0; i < len(signature); i++ {
+		value := body.value(signature[i])
+		if body.err != nil {
+			break // Unsupported or container types end the decoding
+		}
+		msg.Body = append(msg.Body, value)
+	}
+	return msg, nil
+}
+
+// encode encodes the message in little endian
+func (msg *DBusMessage) encode() []byte {
+	var body dbusEncoder
+	var signature string
+	for _, value := range msg.Body {
+		body.value(value)
+		signature += dbusSignatureOf(value)
+	}
+
+	var fields []dbusField
+	for _, field := range []dbusField{
+		{dbusFieldPath, dbusPath(msg.Path)},
+		{dbusFieldInterface, msg.Interface},
+		{dbusFieldMember, msg.Member},
+		{dbusFieldErrorName, msg.ErrorName},
+		{dbusFieldDestination, msg.Destination},
+		{dbusFieldSignature, dbusSignature(signature)},
+	} {
+		if fmt.Sprint(field.Value) != "" {
+			fields = append(fields, field)
+		}
+	}
+	if msg.ReplySerial != 0 {
+		fields = append(fields, dbusField{dbusFieldReplySerial, msg.ReplySerial})
+	}
+
+	header := dbusEncoder{buf: []byte{'l', msg.Type, 0, 1}}
+	header.uint32(uint32(len(body.buf)))
+	header.uint32(msg.Serial)
+	header.value(fields)
+	header.align(8)
+	return append(header.buf, body.buf...)
+}
+
+// dbusSignatureOf returns the D-Bus signature of a supported value
+func dbusSignatureOf(value any) string {
+	switch value.(type) {
+	case byte:
+		return "y"
+	case bool:
+		return "b"
+	case uint32:
+		return "u"
+	case float64:
+		return "d"
+	case string:
+		return "s"
+	case dbusPath:
+		return "o"
+	case dbusSignature:
+		return "g"
+	case dbusVariant:
+		return "v"
+	case []string:
+		return "as"
+	case map[string]any:
+		return "a{sv}"
+	case []dbusField:
+		return "a(yv)"
+	}
+	panic(fmt.Sprintf("unsupported D-Bus value %T", value))
+}
+
+// dbusEncoder encodes values in little endian, aligned relatively to the start of the buffer
+type dbusEncoder struct {
+	buf []byte
+}
+
+func (encoder *dbusEncoder) align(n int) {
+	for len(encoder.buf)%n != 0 {
+		encoder.buf = append(encoder.buf, 0)
+	}
+}
+
+func (encoder *dbusEncoder) uint32(v uint32) {
+	encoder.align(4)
+	encoder.buf = binary.LittleEndian.AppendUint32(encoder.buf, v)
+}
+
+func (encoder *dbusEncoder) string(s string) {
+	encoder.uint32(uint32(len(s)))
+	encoder.buf = append(append(encoder.buf, s...), 0)
+}
+
+// array encodes the length of the elements, which excludes the padding before the first one
+func (encoder *dbusEncoder) array(alignment int, elements func()) {
+	encoder.uint32(0)
+	lengthAt := len(encoder.buf) - 4
+	encoder.align(alignment)
+	start := len(encoder.buf)
+	elements()
+	binary.LittleEndian.PutUint32(encoder.buf[lengthAt:], uint32(len(encoder.buf)-start))
+}
+
+func (encoder *dbusEncoder) value(value any) {
+	switch v := value.(type) {
+	case byte:
+		encoder.buf = append(encoder.buf, v)
+	case bool:
+		if v {
+			encoder.uint32(1)
+		} else {
+			encoder.uint32(0)
+		}
+	case uint32:
+		encoder.uint32(v)
+	case float64:
+		encoder.align(8)
+		encoder.buf = binary.LittleEndian.AppendUint64(encoder.buf, math.Float64bits(v))
+	case string:
+		encoder.string(v)
+	case dbusPath:
+		encoder.string(string(v))
+	case dbusSignature:
+		encoder.buf = append(append(append(encoder.buf, byte(len(v))), v...), 0)
+	case dbusVariant:
+		encoder.value(dbusSignature(dbusSignatureOf(v.Value)))
+		encoder.value(v.Value)
+	case []string:
+		encoder.array(4, func() {
+			for _, s := range v {
+				encoder.string(s)
+			}
+		})
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		encoder.array(8, func() {
+			for _, key := range keys {
+				encoder.align(8)
+				encoder.string(key)
+				encoder.value(dbusVariant{v[key]})
+			}
+		})
+	case []dbusField:
+		encoder.array(8, func() {
+			for _, field := range v {
+				encoder.align(8)
+				encoder.buf = append(encoder.buf, field.Code)
+				encoder.value(dbusVariant{field.Value})
+			}
+		})
+	default:
+		panic(fmt.Sprintf("unsupported D-Bus value %T", value))
+	}
+}
+
+// dbusDecoder decodes basic values, aligned relatively to the start of the data
+type dbusDecoder struct {
+	data  []byte
+	order binary.ByteOrder
+	pos   int
+	err   error
+}
+
+func (decoder *dbusDecoder) align(n int) {
+	decoder.pos = (decoder.pos + n - 1) / n * n
+}
+
+// next returns the next n bytes, or nil once the data is exhausted. The
+// lengths read from the message are checked against the data left, so they
+// can't make it allocate.
+func (decoder *dbusDecoder) next(n int) []byte {
+	if decoder.err == nil && (n < 0 || n > len(decoder.data)-decoder.pos) {
+		decoder.err = errors.New("truncated D-Bus message")
+	}
+	if decoder.err != nil {
+		return nil
+	}
+	decoder.pos += n
+	return decoder.data[decoder.pos-n : decoder.pos]
+}
+
+func (decoder *dbusDecoder) byte() byte {
+	if data := decoder.next(1); data != nil {
+		return data[0]
+	}
+	return 0
+}
+
+func (decoder *dbusDecoder) uint32() uint32 {
+	decoder.align(4)
+	if data := decoder.next(4); data != nil {
+		return decoder.order.Uint32(data)
+	}
+	return 0
+}
+
+// string decodes a string of the given length and its trailing nul byte
+func (decoder *dbusDecoder) string(length int) string {
+	if data := decoder.next(length + 1); data != nil {
+		return string(data[:length])
+	}
+	return ""
+}
+
+// value decodes a value of a basic type, or of a variant of a basic type.
+// Object paths and signatures are returned as strings.
+func (decoder *dbusDecoder) value(code byte) any {
+	switch code {
+	case 'y':
+		return decoder.byte()
+	case 'b':
+		return decoder.uint32() != 0
+	case 'u':
+		return decoder.uint32()
+	case 'd':
+		decoder.align(8)
+		if data := decoder.next(8); data != nil {
+			return math.Float64frombits(decoder.order.Uint64(data))
+		}
+		return 0.0
+	case 's', 'o':
+		return decoder.string(int(decoder.uint32()))
+	case 'g':
+		return decoder.string(int(decoder.byte()))
+	case 'v':
+		signature := decoder.value('g').(string)
+		if len(signature) != 1 {
+			if decoder.err == nil {
+				decoder.err = fmt.Errorf("unsupported variant type %q", signature)
+			}
+			return nil
+		}
+		return decoder.value(signature[0])
+	}
+	if decoder.err == nil {
+		decoder.err = fmt.Errorf("unsupported type %q", code)
+	}
+	return nil
+}
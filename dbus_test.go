@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestDBusEncoder(t *testing.T) {
+	tests := []struct {
+		value any
+		want  []byte
+	}{
+		{byte(7), []byte{7}},
+		{true, []byte{1, 0, 0, 0}},
+		{uint32(0x01020304), []byte{4, 3, 2, 1}},
+		{1.5, []byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f}},
+		{"hi", []byte{2, 0, 0, 0, 'h', 'i', 0}},
+		{dbusPath("/a"), []byte{2, 0, 0, 0, '/', 'a', 0}},
+		{dbusSignature("su"), []byte{2, 's', 'u', 0}},
+		{dbusVariant{uint32(1)}, []byte{1, 'u', 0, 0, 1, 0, 0, 0}},
+		{[]string{"a", "bc"}, []byte{15, 0, 0, 0, 1, 0, 0, 0, 'a', 0, 0, 0, 2, 0, 0, 0, 'b', 'c', 0}},
+		{map[string]any{}, []byte{0, 0, 0, 0, 0, 0, 0, 0}}, // Padded to the first dict entry
+		{map[string]any{"k": true}, []byte{16, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 'k', 0, 1, 'b', 0, 0, 0, 0, 1, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		var encoder dbusEncoder
+		encoder.value(test.value)
+		if !bytes.Equal(encoder.buf, test.want) {
+			t.Errorf("encoding %#v = %v, want %v", test.value, encoder.buf, test.want)
+		}
+	}
+}
+
+// decode decodes an encoded message as Receive does
+func decode(data []byte) (*DBusMessage, error) {
+	headerLength := (16 + int(binary.LittleEndian.Uint32(data[12:])) + 7) / 8 * 8
+	return decodeDBusMessage(data, binary.LittleEndian, headerLength)
+}
+
+func TestDBusMessageRoundTrip(t *testing.T) {
+	tests := []*DBusMessage{
+		{Type: dbusMethodCall, Serial: 1, Destination: "org.freedesktop.DBus", Path: "/org/freedesktop/DBus", Interface: "org.freedesktop.DBus", Member: "Hello"},
+		{Type: dbusMethodReturn, Serial: 2, ReplySerial: 7, Destination: ":1.42", Body: []any{"work", uint32(3), true, 1500.0}},
+		{Type: dbusError, Serial: 3, ReplySerial: 8, ErrorName: "org.freedesktop.DBus.Error.Failed", Body: []any{"failed"}},
+		{Type: dbusSignal, Serial: 4, Path: "/org/gnome/Pomodoro", Interface: "org.gnome.Pomodoro", Member: "StateChanged", Body: []any{byte(1)}},
+	}
+	for _, want := range tests {
+		got, err := decode(want.encode())
+		if err != nil {
+			t.Errorf("decoding %s: %v", want.Member, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("decoded %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestDBusMessageUnsupportedBody(t *testing.T) {
+	// Decoding stops at the first container type
+	msg := &DBusMessage{Type: dbusSignal, Serial: 1, Path: "/a", Member: "M", Body: []any{"state", map[string]any{"k": "v"}, uint32(1)}}
+	got, err := decode(msg.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{"state"}; !reflect.DeepEqual(got.Body, want) {
+		t.Errorf("decoded body %v, want %v", got.Body, want)
+	}
+}
+
+func TestDBusMessageTruncated(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int
+		value  uint32
+	}{
+		{"path past the header", 20, 1000}, // Length of the path, the first field
+		{"huge path", 20, 0xfffffff0},
+	}
+	for _, test := range tests {
+		data := (&DBusMessage{Type: dbusMethodCall, Serial: 1, Path: "/a", Member: "Hello"}).encode()
+		binary.LittleEndian.PutUint32(data[test.offset:], test.value)
+		if _, err := decode(data); err == nil {
+			t.Errorf("%s: decoding succeeded", test.name)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+// CheckDebugAddr rejects the addresses of the debug server reachable from
+// other machines, as the profiles are served without authentication
+func CheckDebugAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%q is not a loopback address (e.g. localhost:6060)", addr)
+	}
+	return nil
+}
+
+// ServeDebug serves the net/http/pprof profiles on addr, e.g. for
+// "go tool pprof http://localhost:6060/debug/pprof/profile". The command line
+// isn't served, as it may hold secrets (e.g. -token).
+func ServeDebug(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	slog.Info("serving profiles", "addr", "http://"+addr+"/debug/pprof/")
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestCheckDebugAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		err  bool
+	}{
+		{"localhost:6060", false},
+		{"127.0.0.1:6060", false},
+		{"[::1]:6060", false},
+		{":6060", true},
+		{"0.0.0.0:6060", true},
+		{"192.168.1.10:6060", true},
+		{"example.com:6060", true},
+		{"localhost", true},
+	}
+	for _, test := range tests {
+		if err := CheckDebugAddr(test.addr); (err != nil) != test.err {
+			t.Errorf("CheckDebugAddr(%q) error = %v, want error %t", test.addr, err, test.err)
+		}
+	}
+}
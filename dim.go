@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Dimmer dims the screen during breaks, to encourage stepping away: when a
+// rest period starts running it lowers the brightness with brightnessctl and
+// shifts the colour temperature with gammastep, and on work (or exit) it
+// restores them. Pauses keep the screen dimmed.
+type Dimmer struct {
+	Brightness  string // brightnessctl value of the breaks, e.g. 30%
+	Temperature int    // Colour temperature of the breaks in Kelvin, 0 to keep the gamma
+
+	dimmed     bool
+	brightness string        // Brightness before the break
+	gamma      *exec.Cmd     // gammastep holding the temperature, on Wayland
+	gammaDone  chan struct{} // Closed when gammastep exits, right away on X11
+}
+
+// Name returns the name of the integration
+func (dimmer *Dimmer) Name() string {
+	return "dim"
+}
+
+// Handle dims the screen on a running rest period, and restores it on work or shutdown
+func (dimmer *Dimmer) Handle(event Event) error {
+	if event.Running(Rest) && !dimmer.dimmed {
+		dimmer.dimmed = true
+		return dimmer.dim()
+	} else if dimmer.dimmed && (event.Status == Work || event.Name == EventShutdown) {
+		dimmer.dimmed = false
+		return dimmer.restore()
+	}
+	return nil
+}
+
+func (dimmer *Dimmer) dim() error {
+	var errs []error
+	if dimmer.Brightness != "" {
+		errs = append(errs, dimmer.dimBrightness())
+	}
+	if dimmer.Temperature > 0 {
+		// Wayland compositors reset the gamma when gammastep exits, so it
+		// keeps running until the break ends, while on X11 it sets it and exits
+		cmd := exec.Command("gammastep", "-P", "-O", fmt.Sprint(dimmer.Temperature))
+		cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM} // Don't outlive the daemon
+		if err := cmd.Start(); err != nil {
+			errs = append(errs, fmt.Errorf("starting gammastep: %w", err))
+		} else {
+			done := make(chan struct{})
+			go func() {
+				cmd.Wait()
+				close(done)
+			}()
+			dimmer.gamma, dimmer.gammaDone = cmd, done
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dimBrightness remembers the brightness, as in the machine-readable output
+// of brightnessctl (e.g. intel_backlight,backlight,1200,50%,2400), and lowers it
+func (dimmer *Dimmer) dimBrightness() error {
+	output, err := exec.Command("brightnessctl", "-m").Output()
+	if err != nil {
+		return fmt.Errorf("brightnessctl -m: %w", err)
+	}
+	fields := strings.Split(strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0]), ",")
+	if len(fields) < 3 {
+		return fmt.Errorf("unexpected brightnessctl output %q", output)
+	}
+	dimmer.brightness = fields[2]
+	return brightnessctl(dimmer.Brightness)
+}
+
+func (dimmer *Dimmer) restore() error {
+	var errs []error
+	if dimmer.brightness != "" {
+		errs = append(errs, brightnessctl(dimmer.brightness))
+		dimmer.brightness = ""
+	}
+	if dimmer.gamma != nil {
+		select {
+		case <-dimmer.gammaDone:
+			if output, err := exec.Command("gammastep", "-x").CombinedOutput(); err != nil {
+				errs = append(errs, fmt.Errorf("gammastep -x: %w: %s", err, strings.TrimSpace(string(output))))
+			}
+		default:
+			errs = append(errs, dimmer.gamma.Process.Signal(syscall.SIGTERM))
+			<-dimmer.gammaDone
+		}
+		dimmer.gamma, dimmer.gammaDone = nil, nil
+	}
+	return errors.Join(errs...)
+}
+
+func brightnessctl(value string) error {
+	if output, err := exec.Command("brightnessctl", "-q", "set", value).CombinedOutput(); err != nil {
+		return fmt.Errorf("brightnessctl set %s: %w: %s", value, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Discord IPC opcodes
+const (
+	discordHandshake = 0
+	discordFrame     = 1
+	discordClose     = 2
+)
+
+// Discord shows the state as the Rich Presence of the local Discord client,
+// through its IPC socket
+type Discord struct {
+	ClientID string // Id of the Discord application the presence is shown for
+
+	conn  net.Conn
+	nonce int
+}
+
+// Name returns the name of the integration
+func (discord *Discord) Name() string {
+	return "discord"
+}
+
+// Handle updates the presence after the event, or clears it on shutdown
+func (discord *Discord) Handle(event Event) error {
+	var activity map[string]any
+	if event.Name != EventShutdown {
+		activity = map[string]any{"details": T("Focusing")}
+		if event.Status == Rest {
+			activity["details"] = T("On a break")
+		}
+		if event.Paused {
+			activity["state"] = T("Paused · %s left", formatClock(event.Remaining))
+		} else {
+			// Discord counts down to the end by itself
+			activity["timestamps"] = map[string]any{"end": event.Time.Add(event.Remaining).UnixMilli()}
+			if event.Task != "" {
+				activity["state"] = event.Task
+			}
+		}
+	}
+
+	err := discord.setActivity(activity)
+	if err != nil && discord.conn != nil {
+		// Discord may have restarted since the connection was opened
+		discord.conn.Close()
+		discord.conn = nil
+		err = discord.setActivity(activity)
+	}
+	if event.Name == EventShutdown && discord.conn != nil {
+		discord.conn.Close()
+	}
+	return err
+}
+
+func (discord *Discord) setActivity(activity map[string]any) error {
+	if discord.conn == nil {
+		if err := discord.connect(); err != nil {
+			return err
+		}
+	}
+	discord.nonce++
+	return discord.send(discordFrame, map[string]any{
+		"cmd":   "SET_ACTIVITY",
+		"args":  map[string]any{"pid": os.Getpid(), "activity": activity},
+		"nonce": fmt.Sprint(discord.nonce),
+	})
+}
+
+// connect opens the first IPC socket Discord listens on and sends the handshake
+func (discord *Discord) connect() error {
+	var dirs []string
+	for _, env := range []string{"XDG_RUNTIME_DIR", "TMPDIR", "TMP", "TEMP"} {
+		if dir := os.Getenv(env); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	dirs = append(dirs, "/tmp")
+
+	var conn net.Conn
+	err := errors.New("discord is not running")
+	for _, dir := range dirs {
+		for i := 0; i < 10 && conn == nil; i++ {
+			conn, _ = net.DialTimeout("unix", filepath.Join(dir, fmt.Sprintf("discord-ipc-%d", i)), PeerTimeout)
+		}
+	}
+	if conn == nil {
+		return err
+	}
+
+	discord.conn = conn
+	if err := discord.send(discordHandshake, map[string]any{"v": 1, "client_id": discord.ClientID}); err != nil {
+		conn.Close()
+		discord.conn = nil
+		return err
+	}
+	return nil
+}
+
+// send writes a frame and reads the reply, returning the error it reports
+func (discord *Discord) send(opcode uint32, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	discord.conn.SetDeadline(time.Now().Add(IntegrationTimeout))
+	frame := binary.LittleEndian.AppendUint32(nil, opcode)
+	frame = binary.LittleEndian.AppendUint32(frame, uint32(len(data)))
+	if _, err := discord.conn.Write(append(frame, data...)); err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(discord.conn, header); err != nil {
+		return err
+	}
+	data = make([]byte, binary.LittleEndian.Uint32(header[4:]))
+	if _, err := io.ReadFull(discord.conn, data); err != nil {
+		return err
+	}
+	var reply struct {
+		Evt  string `json:"evt"`
+		Data struct {
+			Message string `json:"message"`
+		} `json:"data"`
+		Message string `json:"message"` // Reason of a close frame
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(header) == discordClose || reply.Evt == "ERROR" {
+		return fmt.Errorf("discord: %s%s", reply.Data.Message, reply.Message)
+	}
+	return nil
+}
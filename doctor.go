@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Check is a single environment diagnostic run by the doctor subcommand. Run
+// returns a short description of what was found, or an error along with the
+// hint printed to help fixing it.
+type Check struct {
+	Name string
+	Hint string
+	Run  func() (string, error)
+}
+
+// Doctor runs every environment check and prints actionable results,
+// returning an error if any of them failed
+func Doctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	addrFlag := flags.String("addr", "unix://"+SocketPath, "Daemon address (e.g. tcp://127.0.0.1:7777)")
+	tokenFlag := flags.String("token", "", "Shared token of the daemon network listener")
+	flags.Parse(args)
+
+	checks := []Check{
+		{
+			Name: "daemon",
+			Hint: "start polybar-pomo (e.g. from polybar exec) or check the -addr/-token values",
+			Run:  func() (string, error) { return checkDaemon(*addrFlag, *tokenFlag) },
+		},
+		{
+			Name: "config",
+			Hint: "fix the reported line, or run polybar-pomo config validate to list every invalid line",
+			Run:  func() (string, error) { return checkConfig(flag.Lookup("config").Value.String()) },
+		},
+		{
+			Name: "notify-send",
+			Hint: "install libnotify (notify-send) to get notified when a period ends",
+			Run:  func() (string, error) { return exec.LookPath("notify-send") },
+		},
+		{
+			Name: "notification daemon",
+			Hint: "notifications need a D-Bus session bus and a notification daemon (dunst, mako, ...)",
+			Run:  checkNotificationDaemon,
+		},
+		{
+			Name: "status directory",
+			Hint: "set XDG_RUNTIME_DIR to a writable directory, or don't use -status-file",
+			Run:  checkStatusDir,
+		},
+		{
+			Name: "clock",
+			Hint: "enable time synchronization (e.g. systemd-timesyncd) and check the time zone",
+			Run:  checkClock,
+		},
+	}
+
+	failed := 0
+	for _, check := range checks {
+		result, err := check.Run()
+		if err != nil {
+			failed++
+			fmt.Printf("[fail] %s: %s\n       %s\n", check.Name, err.Error(), check.Hint)
+		} else {
+			fmt.Printf("[ok]   %s: %s\n", check.Name, result)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// checkDaemon connects to the daemon and reads the current state from it
+func checkDaemon(addr, token string) (string, error) {
+	conn, err := Dial(addr)
+	if err != nil {
+		if path, found := strings.CutPrefix(addr, "unix://"); found {
+			if _, statErr := os.Stat(path); statErr == nil {
+				return "", fmt.Errorf("stale socket %s: %w", path, err)
+			}
+		}
+		return "", err
+	}
+	defer conn.Close()
+
+	message := "subscribe"
+	if token != "" {
+		message = token + " " + message
+	}
+	conn.SetDeadline(time.Now().Add(PeerTimeout))
+	if _, err := conn.Write([]byte(message + "\n")); err != nil {
+		return "", err
+	}
+
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("no answer from %s (wrong token?)", addr)
+	}
+	return fmt.Sprintf("reachable at %s (%s)", addr, strings.TrimSpace(status)), nil
+}
+
+// checkConfig parses the config file as the daemon does at startup, against
+// a scratch copy of the daemon flags, and checks its values
+func checkConfig(path string) (string, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && path == DefaultConfigPath() {
+		return fmt.Sprintf("no config file at %s, using the defaults", path), nil
+	}
+	file := flag.NewFlagSet(path, flag.ContinueOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) { file.String(f.Name, f.DefValue, "") })
+	if err := LoadConfig(file, path, nil); err != nil {
+		return "", err
+	}
+
+	// The values are set on the daemon flags, as config validate does, for
+	// their type to be checked
+	var err error
+	file.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if err != nil || value == f.DefValue {
+			return
+		}
+		if setErr := setFlag(flag.Lookup(f.Name), value); setErr != nil {
+			err = fmt.Errorf("%s: invalid value for %s: %v", path, f.Name, setErr)
+		} else if check, ok := ConfigChecks[f.Name]; ok {
+			if checkErr := check(value); checkErr != nil {
+				err = fmt.Errorf("%s: invalid value for %s: %v", path, f.Name, checkErr)
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s is valid", path), nil
+}
+
+func checkNotificationDaemon() (string, error) {
+	bus := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if bus == "" {
+		return "", errors.New("DBUS_SESSION_BUS_ADDRESS is not set")
+	}
+	if dbusSend, err := exec.LookPath("dbus-send"); err == nil {
+		cmd := exec.Command(dbusSend, "--session", "--print-reply", "--dest=org.freedesktop.DBus", "/org/freedesktop/DBus",
+			"org.freedesktop.DBus.NameHasOwner", "string:org.freedesktop.Notifications")
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("querying the session bus: %w", err)
+		}
+		if !strings.Contains(string(output), "boolean true") {
+			return "", errors.New("no notification daemon is running")
+		}
+		return "org.freedesktop.Notifications is available", nil
+	}
+	return "session bus at " + bus, nil
+}
+
+func checkStatusDir() (string, error) {
+	dir := StatusDir()
+	if err := MakeStatusDir(dir); err != nil {
+		return "", err
+	}
+	probe, err := os.CreateTemp(dir, ".doctor")
+	if err != nil {
+		return "", err
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return filepath.Clean(dir) + " is writable", nil
+}
+
+// checkClock looks for an unset real-time clock and for wall clock jumps
+// (compared to the monotonic clock) that would skew the timer
+func checkClock() (string, error) {
+	start := time.Now()
+	if start.Year() < 2020 {
+		return "", fmt.Errorf("system time looks unset (%s)", start.Format(time.RFC3339))
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	end := time.Now()
+	drift := end.Round(0).Sub(start.Round(0)) - end.Sub(start)
+	if drift.Abs() > time.Second {
+		return "", fmt.Errorf("wall clock jumped by %s while measuring", drift)
+	}
+
+	zone, _ := start.Zone()
+	return fmt.Sprintf("%s (%s)", start.Format("2006-01-02 15:04:05"), zone), nil
+}
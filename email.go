@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DailyEmail sends a summary of the day's sessions of the history by email,
+// every day at a given time
+type DailyEmail struct {
+	At      string // Time of day, as HH:MM
+	History *History
+
+	Server   string // SMTP server, as host:port
+	User     string // SMTP user, no authentication when empty
+	Password string
+	From     string
+	To       []string
+}
+
+// Run sends the summary every day at the configured time
+func (email *DailyEmail) Run() {
+	for {
+		next, err := nextTimeOfDay(email.At, time.Now())
+		if err != nil {
+			slog.Error("scheduling daily email", "err", err)
+			return
+		}
+		time.Sleep(time.Until(next))
+		if err := email.Send(next); err != nil {
+			slog.Warn("sending daily email", "err", err)
+		}
+	}
+}
+
+// Send sends the summary of the sessions started on the day of the given time
+func (email *DailyEmail) Send(day time.Time) error {
+	sessions, err := email.History.Load()
+	if err != nil {
+		return err
+	}
+	var today []Session
+	for _, session := range sessions {
+		if session.Start.Local().Format(time.DateOnly) == day.Format(time.DateOnly) {
+			today = append(today, session)
+		}
+	}
+	summary := Summarize(today)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%-15s %s\r\n", T("Pomodoros:"), T("%d completed, %d abandoned", summary.Completed, summary.Abandoned))
+	fmt.Fprintf(&body, "%-15s %s\r\n", T("Focus time:"), summary.Focus.Round(time.Minute))
+	if len(summary.Tasks) > 0 {
+		fmt.Fprintf(&body, "\r\n%s\r\n", T("Top tasks:"))
+		for _, label := range summary.TopTasks(5) {
+			fmt.Fprintf(&body, "  %3d  %s\r\n", summary.Tasks[label].Actual, label)
+		}
+	}
+	if len(summary.Notes) > 0 {
+		fmt.Fprintf(&body, "\r\n%s\r\n", T("Notes:"))
+		for _, note := range summary.Notes {
+			fmt.Fprintf(&body, "  %s  %s\r\n", note.Start.Local().Format("15:04"), note.Label())
+		}
+	}
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		email.From, strings.Join(email.To, ", "), mime.QEncoding.Encode("utf-8", T("Pomodoros of %s", day.Format(time.DateOnly))), body.String())
+
+	var auth smtp.Auth
+	if email.User != "" {
+		host, _, _ := net.SplitHostPort(email.Server)
+		auth = smtp.PlainAuth("", email.User, email.Password, host)
+	}
+	return smtp.SendMail(email.Server, auth, email.From, email.To, []byte(message))
+}
+
+// TopTasks returns the labels of the n tasks with the most completed pomodoros, most first
+func (summary Summary) TopTasks(n int) []string {
+	labels := make([]string, 0, len(summary.Tasks))
+	for label := range summary.Tasks {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := summary.Tasks[labels[i]].Actual, summary.Tasks[labels[j]].Actual
+		return a > b || (a == b && labels[i] < labels[j])
+	})
+	return labels[:min(n, len(labels))]
+}
+
+// nextTimeOfDay returns the next time after now at the given HH:MM time of day
+func nextTimeOfDay(clock string, now time.Time) (time.Time, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time of day %q (expected HH:MM)", clock)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), parsed.Hour(), parsed.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
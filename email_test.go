@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextTimeOfDay(t *testing.T) {
+	now := time.Date(2024, time.March, 6, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		clock string
+		want  time.Time
+		err   bool
+	}{
+		{"19:00", time.Date(2024, time.March, 6, 19, 0, 0, 0, time.UTC), false},
+		{"18:31", time.Date(2024, time.March, 6, 18, 31, 0, 0, time.UTC), false},
+		{"18:30", time.Date(2024, time.March, 7, 18, 30, 0, 0, time.UTC), false}, // Now is past
+		{"08:00", time.Date(2024, time.March, 7, 8, 0, 0, 0, time.UTC), false},
+		{"00:00", time.Date(2024, time.March, 7, 0, 0, 0, 0, time.UTC), false},
+		{"8am", time.Time{}, true},
+		{"24:00", time.Time{}, true},
+		{"", time.Time{}, true},
+	}
+	for _, test := range tests {
+		got, err := nextTimeOfDay(test.clock, now)
+		if (err != nil) != test.err {
+			t.Errorf("nextTimeOfDay(%q) error = %v, want error %t", test.clock, err, test.err)
+		} else if !got.Equal(test.want) {
+			t.Errorf("nextTimeOfDay(%q) = %s, want %s", test.clock, got, test.want)
+		}
+	}
+}
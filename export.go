@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// icsTime is the UTC date-time format of iCalendar
+const icsTime = "20060102T150405Z"
+
+// Export writes the history in another format, e.g. for a calendar
+func Export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	fileFlag := flags.String("file", HistoryPath(), "History file")
+	formatFlag := flags.String("format", "ics", "Export format: ics")
+	outputFlag := flags.String("o", "", "Output file instead of stdout")
+	flags.Parse(args)
+
+	if *formatFlag != "ics" {
+		return fmt.Errorf("unknown export format %q", *formatFlag)
+	}
+
+	history := &History{Path: *fileFlag}
+	sessions, err := history.Load()
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputFlag != "" {
+		file, err := os.Create(*outputFlag)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+	return WriteICS(output, sessions)
+}
+
+// WriteICS writes the completed work sessions as events of an iCalendar file,
+// summarized by their task label
+func WriteICS(w io.Writer, sessions []Session) error {
+	writer := bufio.NewWriter(w)
+	line := func(content string) {
+		// Lines are folded at 75 octets, continuation lines starting with a space
+		for len(content) > 75 {
+			cut := 75
+			for cut > 0 && content[cut]&0xC0 == 0x80 {
+				cut-- // Don't split UTF-8 sequences
+			}
+			writer.WriteString(content[:cut] + "\r\n ")
+			content = content[cut:]
+		}
+		writer.WriteString(content + "\r\n")
+	}
+
+	now := time.Now().UTC().Format(icsTime)
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//polybar-pomo//EN")
+	line("X-WR-CALNAME:Pomodoros")
+	for _, session := range sessions {
+		if session.Status != Work.String() || session.Result != Completed {
+			continue
+		}
+		summary := session.Task
+		if summary == "" {
+			summary = "Pomodoro"
+		}
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:%d@polybar-pomo", session.Start.UnixNano()))
+		line("DTSTAMP:" + now)
+		line("DTSTART:" + session.Start.UTC().Format(icsTime))
+		line("DTEND:" + session.End.UTC().Format(icsTime))
+		line("SUMMARY:" + icsEscape(summary))
+		if len(session.Notes) > 0 {
+			line("DESCRIPTION:" + icsEscape(strings.Join(session.Notes, "\n")))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return writer.Flush()
+}
+
+// icsEscape escapes the special characters of iCalendar text values
+func icsEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// EyeBreaks prompts for micro-breaks after every Every of running work (the
+// 20-20-20 rule: every 20 minutes, look at something 20 feet away for 20
+// seconds). They don't touch the timer: the work period keeps running, and
+// the count starts over after every rest period.
+type EyeBreaks struct {
+	Every   time.Duration
+	Length  time.Duration
+	Overlay bool // Whether to cover the screen during the micro-break instead of notifying its end
+
+	worked time.Duration // Running work since the last micro-break
+	last   time.Time     // Previous refresh while running work, zero otherwise
+}
+
+// Name returns the name of the output
+func (breaks *EyeBreaks) Name() string {
+	return "eye breaks"
+}
+
+// Refresh counts the running work time and prompts for a micro-break once it
+// reaches Every. Micro-breaks wait while a window is fullscreen, and are
+// skipped when the work period ends sooner than they do.
+func (breaks *EyeBreaks) Refresh(state *PomodoroState) error {
+	if state.Status != Work {
+		breaks.worked, breaks.last = 0, time.Time{}
+		return nil
+	}
+	if state.Paused {
+		breaks.last = time.Time{}
+		return nil
+	}
+	now := time.Now()
+	if !breaks.last.IsZero() {
+		breaks.worked += now.Sub(breaks.last)
+	}
+	breaks.last = now
+	if breaks.worked < breaks.Every || state.Fullscreen {
+		return nil
+	}
+	breaks.worked = 0
+	if state.Remaining() < breaks.Length {
+		return nil
+	}
+
+	message := T("Look at something far away for %d seconds.", int(breaks.Length.Seconds()))
+	if !breaks.Overlay {
+		Notify(T("Eye break"), message)
+		time.AfterFunc(breaks.Length, func() {
+			Notify(T("Eye break over"), T("Back to work!"))
+		})
+		return nil
+	}
+	// yad closes by itself after the micro-break, or on its skip button
+	args := append([]string{"--text=" + message, "--timeout=" + fmt.Sprint(int(breaks.Length.Seconds())),
+		"--timeout-indicator=bottom", "--button=" + T("Skip") + ":0"}, OverlayArgs...)
+	cmd := exec.Command("yad", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM} // Don't outlive the daemon
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting yad: %w", err)
+	}
+	slog.Debug("showing an eye break", "length", breaks.Length)
+	go cmd.Wait()
+	return nil
+}
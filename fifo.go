@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall"
+)
+
+// FIFO writes updates into a named pipe, waiting for a new reader whenever
+// the current one goes away
+type FIFO struct {
+	Path  string
+	lines chan string
+}
+
+// OpenFIFO creates the named pipe if needed and starts writing updates to it
+func OpenFIFO(path string) (*FIFO, error) {
+	if err := syscall.Mkfifo(path, 0o600); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.Mode()&os.ModeNamedPipe == 0 {
+		return nil, fmt.Errorf("%s exists and is not a named pipe", path)
+	}
+
+	fifo := &FIFO{Path: path, lines: make(chan string, 1)}
+	go fifo.run()
+	return fifo, nil
+}
+
+// Write queues a line for the reader, replacing the pending one if the reader is behind
+func (fifo *FIFO) Write(line string) {
+	select {
+	case <-fifo.lines:
+	default:
+	}
+	fifo.lines <- line
+}
+
+func (fifo *FIFO) run() {
+	for {
+		// Opening blocks until a reader shows up
+		file, err := os.OpenFile(fifo.Path, os.O_WRONLY, 0)
+		if err != nil {
+			slog.Error("opening fifo", "path", fifo.Path, "err", err)
+			return
+		}
+		for line := range fifo.lines {
+			if _, err := file.WriteString(line + "\n"); err != nil {
+				break
+			}
+		}
+		file.Close()
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Window is an application window of the window manager
+type Window struct {
+	ID      string
+	Classes []string // Application classes (e.g. X11 instance and class) or Wayland app id
+	PID     int
+}
+
+// WindowManager closes, hides and restores windows
+type WindowManager interface {
+	Windows() ([]Window, error)
+	Close(window Window) error
+	Minimize(window Window) error
+	Restore(window Window) error
+}
+
+// NewWindowManager returns the backend of a window manager: sway (through
+// swaymsg), wmctrl for X11 window managers, or auto to pick sway when running
+func NewWindowManager(name string) (WindowManager, error) {
+	if name == "auto" {
+		name = "wmctrl"
+		if os.Getenv("SWAYSOCK") != "" {
+			name = "sway"
+		}
+	}
+	switch name {
+	case "sway":
+		return Sway{}, nil
+	case "wmctrl":
+		return Wmctrl{}, nil
+	}
+	return nil, fmt.Errorf("unknown window manager %q (expected auto, sway or wmctrl)", name)
+}
+
+// Focus manages the applications of focus sessions: when a work period
+// starts running, it closes and minimizes the distracting applications and
+// starts the needed ones, and on break (or exit) it undoes it all, restoring
+// the minimized windows, starting the closed applications again and stopping
+// the ones it started
+type Focus struct {
+	WM       WindowManager
+	Close    []string // Classes of the applications to close
+	Minimize []string // Classes of the applications to minimize
+	Start    []string // Shell commands of the applications to start
+
+	active    bool
+	minimized []Window
+	closed    [][]string // Command lines of the closed applications
+	started   []*exec.Cmd
+}
+
+// Name returns the name of the integration
+func (focus *Focus) Name() string {
+	return "focus"
+}
+
+// Handle starts the focus session on a running work period, and ends it on
+// rest or shutdown. Pauses keep the session.
+func (focus *Focus) Handle(event Event) error {
+	if event.Running(Work) && !focus.active {
+		focus.active = true
+		return focus.enter()
+	} else if focus.active && (event.Status == Rest || event.Name == EventShutdown) {
+		focus.active = false
+		return focus.leave()
+	}
+	return nil
+}
+
+func (focus *Focus) enter() error {
+	windows, err := focus.WM.Windows()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	closedPIDs := make(map[int]bool) // Applications with several windows are started again once
+	for _, window := range windows {
+		if matchClass(window.Classes, focus.Close) {
+			if args := processArgs(window.PID); args != nil && !closedPIDs[window.PID] {
+				focus.closed = append(focus.closed, args)
+				closedPIDs[window.PID] = true
+			}
+			if err := focus.WM.Close(window); err != nil {
+				errs = append(errs, err)
+			}
+		} else if matchClass(window.Classes, focus.Minimize) {
+			if err := focus.WM.Minimize(window); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			focus.minimized = append(focus.minimized, window)
+		}
+	}
+	for _, command := range focus.Start {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // Stopped along with its children
+		if err := cmd.Start(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		go cmd.Wait()
+		focus.started = append(focus.started, cmd)
+	}
+	return errors.Join(errs...)
+}
+
+func (focus *Focus) leave() error {
+	var errs []error
+	for _, window := range focus.minimized {
+		if err := focus.WM.Restore(window); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, args := range focus.closed {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true} // Outlives the daemon
+		if err := cmd.Start(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		go cmd.Wait()
+	}
+	for _, cmd := range focus.started {
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+			errs = append(errs, err)
+		}
+	}
+	focus.minimized, focus.closed, focus.started = nil, nil, nil
+	return errors.Join(errs...)
+}
+
+// matchClass returns whether one of the classes of a window is in the list, in any case
+func matchClass(classes, list []string) bool {
+	for _, class := range classes {
+		for _, candidate := range list {
+			if strings.EqualFold(class, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// processArgs returns the command line of a process, nil when unknown
+func processArgs(pid int) []string {
+	if pid <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil || len(data) == 0 {
+		slog.Debug("reading the command line of a closed application", "pid", pid, "err", err)
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+}
+
+// Wmctrl controls the windows of X11 window managers with wmctrl
+type Wmctrl struct{}
+
+// Windows lists the windows with their WM_CLASS, matched on both its instance and class parts
+func (Wmctrl) Windows() ([]Window, error) {
+	output, err := exec.Command("wmctrl", "-lpx").Output()
+	if err != nil {
+		return nil, fmt.Errorf("wmctrl -lpx: %w", err)
+	}
+	var windows []Window
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// e.g. 0x03a00003  0 12345  Navigator.firefox  host  Title
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		pid, _ := strconv.Atoi(fields[2])
+		instance, class, _ := strings.Cut(fields[3], ".")
+		windows = append(windows, Window{ID: fields[0], Classes: []string{instance, class}, PID: pid})
+	}
+	return windows, scanner.Err()
+}
+
+// Close closes a window gracefully
+func (Wmctrl) Close(window Window) error {
+	return wmctrl("-ic", window.ID)
+}
+
+// Minimize hides a window
+func (Wmctrl) Minimize(window Window) error {
+	return wmctrl("-ir", window.ID, "-b", "add,hidden")
+}
+
+// Restore shows a hidden window again
+func (Wmctrl) Restore(window Window) error {
+	return wmctrl("-ir", window.ID, "-b", "remove,hidden")
+}
+
+func wmctrl(args ...string) error {
+	if output, err := exec.Command("wmctrl", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("wmctrl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Sway controls the windows of sway with swaymsg, minimizing to the scratchpad
+type Sway struct{}
+
+// swayNode is a node of the sway layout tree
+type swayNode struct {
+	ID               int    `json:"id"`
+	PID              int    `json:"pid"`
+	AppID            string `json:"app_id"`
+	WindowProperties struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Nodes         []swayNode `json:"nodes"`
+	FloatingNodes []swayNode `json:"floating_nodes"`
+}
+
+// Windows lists the windows of the tree, by Wayland app id or X11 class
+func (Sway) Windows() ([]Window, error) {
+	output, err := exec.Command("swaymsg", "-t", "get_tree").Output()
+	if err != nil {
+		return nil, fmt.Errorf("swaymsg -t get_tree: %w", err)
+	}
+	var root swayNode
+	if err := json.Unmarshal(output, &root); err != nil {
+		return nil, err
+	}
+
+	var windows []Window
+	var walk func(node swayNode)
+	walk = func(node swayNode) {
+		class := node.AppID
+		if class == "" {
+			class = node.WindowProperties.Class
+		}
+		if class != "" {
+			windows = append(windows, Window{ID: strconv.Itoa(node.ID), Classes: []string{class}, PID: node.PID})
+		}
+		for _, child := range append(node.Nodes, node.FloatingNodes...) {
+			walk(child)
+		}
+	}
+	walk(root)
+	return windows, nil
+}
+
+// Close kills a window gracefully
+func (Sway) Close(window Window) error {
+	return swaymsg(window, "kill")
+}
+
+// Minimize moves a window to the scratchpad
+func (Sway) Minimize(window Window) error {
+	return swaymsg(window, "move scratchpad")
+}
+
+// Restore brings a window back from the scratchpad, tiled
+func (Sway) Restore(window Window) error {
+	return swaymsg(window, "scratchpad show, floating disable")
+}
+
+func swaymsg(window Window, command string) error {
+	criteria := fmt.Sprintf("[con_id=%s] %s", window.ID, command)
+	if output, err := exec.Command("swaymsg", criteria).CombinedOutput(); err != nil {
+		return fmt.Errorf("swaymsg %s: %w: %s", criteria, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
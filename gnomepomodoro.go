@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Names of the gnome-pomodoro D-Bus service
+const (
+	GnomePomodoroName      = "org.gnome.Pomodoro"
+	GnomePomodoroPath      = "/org/gnome/Pomodoro"
+	GnomePomodoroInterface = "org.gnome.Pomodoro"
+	GnomePomodoroVersion   = "0.24.0" // Version of gnome-pomodoro whose interface is implemented
+)
+
+// gnomePomodoroIntrospection describes the implemented subset of the interface
+const gnomePomodoroIntrospection = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node>
+  <interface name="org.gnome.Pomodoro">
+    <property name="Elapsed" type="d" access="read"/>
+    <property name="State" type="s" access="read"/>
+    <property name="StateDuration" type="d" access="read"/>
+    <property name="IsPaused" type="b" access="read"/>
+    <property name="Version" type="s" access="read"/>
+    <method name="SetState">
+      <arg name="state" type="s" direction="in"/>
+      <arg name="timestamp" type="d" direction="in"/>
+    </method>
+    <method name="Start"/>
+    <method name="Stop"/>
+    <method name="Reset"/>
+    <method name="Pause"/>
+    <method name="Resume"/>
+    <method name="Skip"/>
+    <signal name="Paused"/>
+    <signal name="Resumed"/>
+  </interface>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="properties" type="a{sv}" direction="out"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface" type="s"/>
+      <arg name="changed" type="a{sv}"/>
+      <arg name="invalidated" type="as"/>
+    </signal>
+  </interface>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect">
+      <arg name="xml" type="s" direction="out"/>
+    </method>
+  </interface>
+</node>`
+
+// GnomePomodoro serves a subset of the org.gnome.Pomodoro D-Bus interface of
+// gnome-pomodoro, so the GNOME Shell extensions and scripts written for it
+// can drive the daemon. Work periods are reported as the "pomodoro" state
+// and rest periods as "short-break".
+type GnomePomodoro struct {
+	bus      *DBusConn
+	commands chan Command
+
+	mutex   sync.Mutex
+	current gnomePomodoroState
+}
+
+// gnomePomodoroState is the part of the json output followed by the service
+type gnomePomodoroState struct {
+	Status    string `json:"status"`
+	Paused    bool   `json:"paused"`
+	Remaining int    `json:"remaining"`
+	Duration  int    `json:"duration"`
+	Received  time.Time
+}
+
+// ServeGnomePomodoro owns the org.gnome.Pomodoro name on the session bus and
+// answers its method calls until the connection is lost
+func ServeGnomePomodoro(commands chan Command) error {
+	bus, err := DialSessionBus()
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+
+	const doNotQueue = 4
+	reply, err := bus.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RequestName", GnomePomodoroName, uint32(doNotQueue))
+	if err != nil {
+		return err
+	}
+	if len(reply.Body) != 1 || reply.Body[0] != uint32(1) {
+		return fmt.Errorf("%s is already owned (is gnome-pomodoro running?)", GnomePomodoroName)
+	}
+	slog.Debug("serving on the session bus", "name", GnomePomodoroName)
+
+	service := &GnomePomodoro{bus: bus, commands: commands}
+	updates := make(chan string, 8)
+	commands <- Command{Name: "subscribe", Args: []string{"json"}, Reply: updates}
+	go service.Follow(updates)
+
+	for {
+		msg, err := bus.Receive()
+		if err != nil {
+			return err
+		}
+		if msg.Type == dbusMethodCall {
+			if err := service.Handle(msg); err != nil {
+				slog.Warn("answering d-bus call", "member", msg.Member, "err", err)
+			}
+		}
+	}
+}
+
+// Follow keeps track of the state rendered by the daemon, and signals its changes
+func (service *GnomePomodoro) Follow(updates chan string) {
+	for update := range updates {
+		var next gnomePomodoroState
+		if err := json.Unmarshal([]byte(update), &next); err != nil {
+			slog.Warn("decoding state", "err", err)
+			continue
+		}
+		next.Received = time.Now()
+
+		service.mutex.Lock()
+		previous := service.current
+		service.current = next
+		properties := service.properties()
+		service.mutex.Unlock()
+
+		changed := make(map[string]any)
+		if next.Status != previous.Status {
+			changed["State"], changed["StateDuration"] = properties["State"], properties["StateDuration"]
+		}
+		if next.Paused != previous.Paused {
+			changed["IsPaused"] = next.Paused
+			signal := "Resumed"
+			if next.Paused {
+				signal = "Paused"
+			}
+			service.bus.Emit(GnomePomodoroPath, GnomePomodoroInterface, signal)
+		}
+		if len(changed) > 0 {
+			service.bus.Emit(GnomePomodoroPath, "org.freedesktop.DBus.Properties", "PropertiesChanged", GnomePomodoroInterface, changed, []string{})
+		}
+	}
+	slog.Warn("d-bus service unsubscribed from the state updates")
+}
+
+// properties returns the properties of the interface, the mutex being held
+func (service *GnomePomodoro) properties() map[string]any {
+	current := service.current
+	remaining := time.Duration(current.Remaining) * time.Second
+	if !current.Paused {
+		remaining -= time.Since(current.Received)
+	}
+	state := "pomodoro"
+	if current.Status == Rest.String() {
+		state = "short-break"
+	}
+	return map[string]any{
+		"Elapsed":       float64(current.Duration) - remaining.Seconds(),
+		"State":         state,
+		"StateDuration": float64(current.Duration),
+		"IsPaused":      current.Paused,
+		"Version":       GnomePomodoroVersion,
+	}
+}
+
+// Handle answers a method call, translating the gnome-pomodoro methods into commands
+func (service *GnomePomodoro) Handle(call *DBusMessage) error {
+	service.mutex.Lock()
+	properties := service.properties()
+	status, paused := service.current.Status, service.current.Paused
+	service.mutex.Unlock()
+
+	// The pause command toggles the paused state
+	var cmds []Command
+	switch call.Member {
+	case "Introspect":
+		return service.bus.Reply(call, gnomePomodoroIntrospection)
+	case "Ping":
+		return service.bus.Reply(call)
+	case "Get":
+		if len(call.Body) == 2 {
+			if value, ok := properties[fmt.Sprint(call.Body[1])]; ok {
+				return service.bus.Reply(call, dbusVariant{value})
+			}
+		}
+		return service.bus.ReplyError(call, "org.freedesktop.DBus.Error.UnknownProperty", fmt.Sprintf("unknown property %v", call.Body))
+	case "GetAll":
+		return service.bus.Reply(call, properties)
+	case "Start", "Resume":
+		if paused {
+			cmds = append(cmds, Command{Name: "pause"})
+		}
+	case "Pause":
+		if !paused {
+			cmds = append(cmds, Command{Name: "pause"})
+		}
+	case "Stop":
+		if !paused {
+			cmds = append(cmds, Command{Name: "pause"})
+		}
+		cmds = append(cmds, Command{Name: "restart"})
+	case "Reset":
+		cmds = append(cmds, Command{Name: "restart"})
+	case "Skip":
+		cmds = append(cmds, Command{Name: "toggle"})
+	case "SetState":
+		if len(call.Body) == 0 {
+			return service.bus.ReplyError(call, "org.freedesktop.DBus.Error.InvalidArgs", "expected a state")
+		}
+		switch call.Body[0] {
+		case "pomodoro":
+			if status != Work.String() {
+				cmds = append(cmds, Command{Name: "toggle"})
+			}
+		case "short-break", "long-break":
+			if status != Rest.String() {
+				cmds = append(cmds, Command{Name: "toggle", Args: []string{"force"}})
+			}
+		case "null":
+			if !paused {
+				cmds = append(cmds, Command{Name: "pause"})
+			}
+		default:
+			return service.bus.ReplyError(call, "org.freedesktop.DBus.Error.InvalidArgs", fmt.Sprintf("unknown state %v", call.Body[0]))
+		}
+	default:
+		return service.bus.ReplyError(call, "org.freedesktop.DBus.Error.UnknownMethod", fmt.Sprintf("unknown method %s.%s", call.Interface, call.Member))
+	}
+
+	if len(cmds) > 0 {
+		service.commands <- Command{Name: "batch", Batch: cmds}
+	}
+	return service.bus.Reply(call)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Session results recorded in the history
+const (
+	Completed = "completed" // The period ran to the end, or was toggled to the next one
+	Abandoned = "abandoned" // The work period was given up with the abandon command
+	Aborted   = "aborted"   // The daemon stopped unexpectedly during the period
+)
+
+// Seconds is a duration serialized as a number of seconds
+type Seconds time.Duration
+
+// MarshalJSON encodes the duration as whole seconds
+func (s Seconds) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int64(time.Duration(s).Round(time.Second).Seconds()))
+}
+
+// UnmarshalJSON decodes a number of seconds
+func (s *Seconds) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return err
+	}
+	*s = Seconds(seconds * float64(time.Second))
+	return nil
+}
+
+// Session is a finished period, stored as one JSON object per line in the history file
+type Session struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Status  string    `json:"status"`
+	Elapsed Seconds   `json:"elapsed"` // Time spent running, excluding pauses
+	Result  string    `json:"result"`
+
+	Interruptions []string `json:"interruptions,omitempty"` // Reasons of the interruptions
+	Notes         []string `json:"notes,omitempty"`
+	Task          string   `json:"task,omitempty"`     // Label of the task of a work session
+	Estimate      int      `json:"estimate,omitempty"` // Estimated number of pomodoros of the task
+}
+
+// History is the append-only file of finished sessions
+type History struct {
+	Path   string
+	MaxAge time.Duration // Sessions older than this are trimmed (0 keeps them all)
+
+	trimmed string // Day of the last trim, done at most once a day
+}
+
+// HistoryPath returns the default path of the history file
+func HistoryPath() string {
+	return filepath.Join(DataDir(), "history.jsonl")
+}
+
+// DataDir returns the directory of the persistent data files, under XDG_DATA_HOME when set
+func DataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "polybar-pomo")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "polybar-pomo")
+}
+
+// Load reads every session of the history file
+func (history *History) Load() ([]Session, error) {
+	file, err := os.Open(history.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sessions []Session
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal(scanner.Bytes(), &session); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", history.Path, lineNumber, err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, scanner.Err()
+}
+
+// CountCompleted returns the number of work sessions completed on the day of the given time
+func (history *History) CountCompleted(day time.Time) (int, error) {
+	sessions, err := history.Load()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, session := range sessions {
+		if session.Status == Work.String() && session.Result == Completed &&
+			session.Start.Local().Format(time.DateOnly) == day.Format(time.DateOnly) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Append writes the session at the end of the history file
+func (history *History) Append(session Session) error {
+	line, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(history.Path), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(history.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if today := time.Now().Format(time.DateOnly); history.MaxAge > 0 && history.trimmed != today {
+		history.trimmed = today
+		return history.Trim(time.Now().Add(-history.MaxAge))
+	}
+	return nil
+}
+
+// Trim removes the sessions started before the cutoff from the history file
+func (history *History) Trim(cutoff time.Time) error {
+	sessions, err := history.Load()
+	if err != nil {
+		return err
+	}
+	kept := sessions[:0]
+	for _, session := range sessions {
+		if !session.Start.Before(cutoff) {
+			kept = append(kept, session)
+		}
+	}
+	if len(kept) == len(sessions) {
+		return nil
+	}
+	slog.Info("trimming history", "path", history.Path, "sessions", len(sessions)-len(kept))
+	return history.Rewrite(kept)
+}
+
+// DropLast removes the last session of the history file
+func (history *History) DropLast() error {
+	data, err := os.ReadFile(history.Path)
+	if err != nil {
+		return err
+	}
+
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+	if len(lines) <= 1 {
+		return os.Remove(history.Path)
+	}
+	return writeFileAtomic(history.Path, string(bytes.Join(lines[:len(lines)-1], []byte("\n"))))
+}
+
+// AmendLast rewrites the last session of the history file after applying change to it
+func (history *History) AmendLast(change func(session *Session)) error {
+	data, err := os.ReadFile(history.Path)
+	if err != nil {
+		return err
+	}
+
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+	last := len(lines) - 1
+	var session Session
+	if err := json.Unmarshal(lines[last], &session); err != nil {
+		return fmt.Errorf("%s:%d: %w", history.Path, last+1, err)
+	}
+
+	change(&session)
+	if lines[last], err = json.Marshal(session); err != nil {
+		return err
+	}
+	return writeFileAtomic(history.Path, string(bytes.Join(lines, []byte("\n"))))
+}
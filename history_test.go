@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistoryMaxAge(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	session := func(age time.Duration) Session {
+		return Session{Start: now.Add(-age), End: now.Add(-age + 25*time.Minute), Status: Work.String(), Result: Completed}
+	}
+	old, recent := session(40*24*time.Hour), session(2*24*time.Hour)
+
+	history := &History{Path: filepath.Join(t.TempDir(), "history.jsonl"), MaxAge: 30 * 24 * time.Hour}
+	if err := history.Rewrite([]Session{old, recent}); err != nil {
+		t.Fatal(err)
+	}
+	latest := session(time.Hour)
+	if err := history.Append(latest); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := history.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Session{recent, latest}; !reflect.DeepEqual(sessions, want) {
+		t.Errorf("history = %v, want %v", sessions, want)
+	}
+}
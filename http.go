@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KeepAliveInterval is how often an idle event stream receives a comment, so
+// proxies don't close it
+const KeepAliveInterval = 15 * time.Second
+
+// ServeHTTPAPI serves the HTTP API on addr, over HTTPS when tlsConfig isn't
+// nil, for dashboards and browser sources which can't open a socket: /events
+// streams the state as server-sent events
+func ServeHTTPAPI(addr string, tlsConfig *tls.Config, access Access, commands chan Command) error {
+	mux := http.NewServeMux()
+	mux.Handle("/events", Events(access, commands))
+	server := &http.Server{Addr: addr, Handler: mux, TLSConfig: tlsConfig, ReadHeaderTimeout: 10 * time.Second}
+	if tlsConfig != nil {
+		slog.Info("serving the HTTP API", "addr", "https://"+addr+"/events")
+		return server.ListenAndServeTLS("", "")
+	}
+	slog.Info("serving the HTTP API", "addr", "http://"+addr+"/events")
+	return server.ListenAndServe()
+}
+
+// Events streams every update of the state as a server-sent event, rendered in
+// the ?format= output format (json by default). The token is read from a
+// bearer Authorization header or from ?token=, as EventSource can't set headers.
+func Events(access Access, commands chan Command) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+
+		message, permission, ok := access.Authenticate(strings.TrimSpace(token + " subscribe " + format))
+		if !ok {
+			slog.Warn("rejecting unauthenticated request", "remote", r.RemoteAddr)
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		cmd := ParseBatch(message)
+		if !Limiter.Allow(remoteHost(r.RemoteAddr), 1) {
+			slog.Warn("rejecting request, rate limit exceeded", "command", cmd.String(), "remote", r.RemoteAddr)
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		if !permission.Allows(cmd) {
+			http.Error(w, "read-only connection", http.StatusForbidden)
+			return
+		}
+		if _, ok := Renderers[format]; !ok {
+			http.Error(w, fmt.Sprintf("unknown output format %q", format), http.StatusBadRequest)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		cmd.Reply = make(chan string, 8)
+		commands <- cmd
+
+		keepAlive := time.NewTicker(KeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case update, ok := <-cmd.Reply:
+				if !ok {
+					return
+				}
+				// Every line of a multi-line update is a data field of the same event
+				fmt.Fprintf(w, "data: %s\n\n", strings.ReplaceAll(update, "\n", "\ndata: "))
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case <-r.Context().Done():
+				// The subscriber is dropped once its stream is full
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
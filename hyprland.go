@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Hyprland sends commands to Hyprland on transitions, e.g. to change the
+// border colour or the submap, from templates of the event by state
+type Hyprland struct {
+	Templates map[string]*template.Template // Commands by class (work, rest, paused or exit)
+
+	last string // Class of the commands run last
+}
+
+// NewHyprland creates the integration from the templates by class, skipping the empty ones
+func NewHyprland(templates map[string]string) (*Hyprland, error) {
+	hyprland := &Hyprland{Templates: make(map[string]*template.Template)}
+	for class, text := range templates {
+		if text == "" {
+			continue
+		}
+		parsed, err := template.New(class).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hyprland %s template: %w", class, err)
+		}
+		hyprland.Templates[class] = parsed
+	}
+	return hyprland, nil
+}
+
+// Name returns the name of the integration
+func (hyprland *Hyprland) Name() string {
+	return "hyprland"
+}
+
+// Handle runs the commands of the state after the event, or the exit ones on shutdown
+func (hyprland *Hyprland) Handle(event Event) error {
+	class := event.Class()
+	if event.Name == EventShutdown {
+		class = "exit"
+	}
+	commands, ok := hyprland.Templates[class]
+	if class == hyprland.last || !ok {
+		return nil
+	}
+	hyprland.last = class
+
+	var text bytes.Buffer
+	if err := commands.Execute(&text, event); err != nil {
+		return err
+	}
+	return HyprctlBatch(text.String())
+}
+
+// HyprctlBatch sends semicolon-separated commands (e.g. "keyword
+// general:col.active_border rgb(ff0000); dispatch submap focus") to Hyprland
+func HyprctlBatch(commands string) error {
+	reply, err := Hyprctl("[[BATCH]]" + commands)
+	if err != nil {
+		return err
+	}
+	// Hyprland answers "ok" for every successful command
+	for _, line := range strings.Split(strings.TrimSpace(string(reply)), "\n\n") {
+		if line = strings.TrimSpace(line); line != "" && line != "ok" {
+			return fmt.Errorf("hyprland: %s", line)
+		}
+	}
+	return nil
+}
+
+// Hyprctl sends a request to Hyprland (e.g. "j/activeworkspace" for its
+// JSON reply) and returns the reply
+func Hyprctl(request string) ([]byte, error) {
+	path, err := hyprlandSocket(".socket.sock")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", path, PeerTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(IntegrationTimeout))
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(conn)
+}
+
+// WatchHyprland reads the events of Hyprland and sends the fullscreen hints
+// to the main loop, so notifications wait until leaving fullscreen
+func WatchHyprland(commands chan Command) error {
+	path, err := hyprlandSocket(".socket2.sock")
+	if err != nil {
+		return err
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		// e.g. fullscreen>>1
+		name, data, _ := strings.Cut(scanner.Text(), ">>")
+		if name == "fullscreen" {
+			state := "off"
+			if data == "1" {
+				state = "on"
+			}
+			commands <- Command{Name: "fullscreen", Args: []string{state}}
+		}
+	}
+	return scanner.Err()
+}
+
+// hyprlandSocket returns the path of a socket of the running Hyprland instance
+func hyprlandSocket(name string) (string, error) {
+	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if signature == "" {
+		return "", errors.New("hyprland is not running (HYPRLAND_INSTANCE_SIGNATURE is not set)")
+	}
+	// Hyprland moved its sockets from /tmp to the runtime directory in v0.40
+	for _, dir := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/tmp"} {
+		path := filepath.Join(dir, "hypr", signature, name)
+		if _, err := os.Stat(path); dir != "" && err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("hyprland socket %s not found", name)
+}
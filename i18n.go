@@ -0,0 +1,67 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Locales are the message catalogs, one JSON object per language mapping the
+// English messages to their translation
+//
+//go:embed locales/*.json
+var Locales embed.FS
+
+// catalog is the message catalog of the current language, empty for English
+var catalog map[string]string
+
+// SetLanguage loads the catalog of the language (e.g. de or pt_BR.UTF-8),
+// detected from the locale environment variables when empty
+func SetLanguage(lang string) error {
+	if lang == "" {
+		for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if lang = os.Getenv(name); lang != "" {
+				break
+			}
+		}
+	}
+	// Only the language matters, e.g. pt_BR.UTF-8 is pt
+	lang, _, _ = strings.Cut(lang, ".")
+	lang, _, _ = strings.Cut(lang, "_")
+	if lang == "" || lang == "C" || lang == "POSIX" || lang == "en" {
+		catalog = nil
+		return nil
+	}
+
+	data, err := Locales.ReadFile("locales/" + lang + ".json")
+	if err != nil {
+		return fmt.Errorf("unsupported language %q", lang)
+	}
+	return json.Unmarshal(data, &catalog)
+}
+
+// Labels are the custom labels of the states by class (work, rest or
+// paused), replacing the translated names of the states
+var Labels = map[string]string{}
+
+// Label returns the label of the state with the given class
+func Label(class string) string {
+	if label, ok := Labels[class]; ok {
+		return label
+	}
+	return T(class)
+}
+
+// T translates the message to the current language, formatting it with the
+// arguments if any. Messages missing from the catalog are kept in English.
+func T(message string, args ...any) string {
+	if translation, ok := catalog[message]; ok {
+		message = translation
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Idle pauses the running timer when the user goes idle, remembering to
+// resume it once they are back
+func (state *PomodoroState) Idle() {
+	if !state.Paused {
+		state.Pause()
+		state.IdlePaused = true
+	}
+}
+
+// Active resumes the timer paused by Idle, unless it was resumed or paused
+// by hand since then
+func (state *PomodoroState) Active() {
+	if state.IdlePaused && state.Paused {
+		state.Pause()
+	}
+	state.IdlePaused = false
+}
+
+// WatchIdle runs swayidle, which detects idleness on Wayland compositors
+// through the ext-idle-notify-v1 protocol, and sends the idle and active
+// commands it prints to the main loop. It returns when swayidle exits.
+func WatchIdle(timeout time.Duration, commands chan Command) error {
+	seconds := fmt.Sprint(max(1, int(timeout.Seconds())))
+	cmd := exec.Command("swayidle", "timeout", seconds, "echo idle", "resume", "echo active")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM} // Don't outlive the daemon
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	slog.Debug("watching idleness", "timeout", timeout)
+	ReadCommands(stdout, commands)
+	return cmd.Wait()
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gnomePomodoroQuery selects the entries of the gnome-pomodoro database
+const gnomePomodoroQuery = `SELECT "datetime-string", "state-name", "state-duration", "elapsed" FROM entries ORDER BY "datetime-string"`
+
+// importTimes are the time formats accepted in imported files, the ones
+// without an offset being read in the local time zone
+var importTimes = []string{time.RFC3339, "2006-01-02T15:04:05-0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// Import converts the sessions of another pomodoro app into the history,
+// skipping the ones it already holds
+func Import(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	fromFlag := flags.String("from", "", "Format of the source: gnome-pomodoro or csv")
+	fileFlag := flags.String("file", HistoryPath(), "History file")
+	dryRunFlag := flags.Bool("dry-run", false, "Print the number of imported sessions without writing them")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: polybar-pomo import -from gnome-pomodoro|csv [flags] [source]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var imported []Session
+	var err error
+	switch *fromFlag {
+	case "gnome-pomodoro":
+		source := filepath.Join(filepath.Dir(DataDir()), "gnome-pomodoro", "database.sqlite")
+		if flags.NArg() > 0 {
+			source = flags.Arg(0)
+		}
+		imported, err = ImportGnomePomodoro(source)
+	case "csv":
+		if flags.NArg() == 0 {
+			return errors.New("missing the CSV file to import")
+		}
+		file, openErr := os.Open(flags.Arg(0))
+		if openErr != nil {
+			return openErr
+		}
+		defer file.Close()
+		imported, err = ImportCSV(file)
+	case "pomotroid":
+		return errors.New("pomotroid doesn't keep a history of its sessions, only its settings")
+	default:
+		return fmt.Errorf("unknown source format %q (expected gnome-pomodoro or csv)", *fromFlag)
+	}
+	if err != nil {
+		return err
+	}
+
+	history := &History{Path: *fileFlag}
+	sessions, err := history.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	merged, added := MergeSessions(sessions, imported)
+	fmt.Printf("%d sessions imported, %d already in the history\n", added, len(imported)-added)
+	if *dryRunFlag || added == 0 {
+		return nil
+	}
+	return history.Rewrite(merged)
+}
+
+// MergeSessions adds the imported sessions missing from the history, sorted by
+// start time, and returns the number of sessions added
+func MergeSessions(sessions, imported []Session) ([]Session, int) {
+	type key struct {
+		start  int64
+		status string
+	}
+	seen := make(map[key]bool)
+	for _, session := range sessions {
+		seen[key{session.Start.Unix(), session.Status}] = true
+	}
+
+	added := 0
+	for _, session := range imported {
+		if k := (key{session.Start.Unix(), session.Status}); !seen[k] {
+			seen[k] = true
+			sessions = append(sessions, session)
+			added++
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Start.Before(sessions[j].Start) })
+	return sessions, added
+}
+
+// Rewrite replaces the history file with the sessions
+func (history *History) Rewrite(sessions []Session) error {
+	var lines [][]byte
+	for _, session := range sessions {
+		line, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+	if err := os.MkdirAll(filepath.Dir(history.Path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(history.Path, string(bytes.Join(lines, []byte("\n"))))
+}
+
+// ImportGnomePomodoro reads the sessions of the gnome-pomodoro database with
+// the sqlite3 command. Pomodoros are imported as work sessions and breaks as
+// rest sessions, the ones stopped early as abandoned.
+func ImportGnomePomodoro(path string) ([]Session, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	output, err := exec.Command("sqlite3", "-readonly", "-csv", path, gnomePomodoroQuery).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("sqlite3: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("running sqlite3: %w", err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	for _, record := range records {
+		status := map[string]string{"pomodoro": Work.String(), "short-break": Rest.String(), "long-break": Rest.String()}[record[1]]
+		if status == "" {
+			continue
+		}
+		start, err := parseImportTime(record[0])
+		if err != nil {
+			return nil, err
+		}
+		duration, err1 := strconv.ParseFloat(record[2], 64)
+		elapsed, err2 := strconv.ParseFloat(record[3], 64)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, fmt.Errorf("entry of %s: %w", record[0], err)
+		}
+
+		result := Completed
+		if elapsed < duration-1 {
+			result = Abandoned
+		}
+		sessions = append(sessions, Session{
+			Start:   start,
+			End:     start.Add(time.Duration(elapsed * float64(time.Second))),
+			Status:  status,
+			Elapsed: Seconds(elapsed * float64(time.Second)),
+			Result:  result,
+		})
+	}
+	return sessions, nil
+}
+
+// ImportCSV reads sessions from a CSV file with a header naming its columns:
+// start (required), end, status (work or rest, work by default), elapsed
+// (seconds or a duration), result (completed by default) and task. Either end
+// or elapsed must be given.
+func ImportCSV(r io.Reader) ([]Session, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["start"]; !ok {
+		return nil, errors.New("missing the start column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var sessions []Session
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return sessions, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		session := Session{Status: Work.String(), Result: Completed, Task: field(record, "task")}
+		if session.Start, err = parseImportTime(field(record, "start")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if status := field(record, "status"); status != "" {
+			parsed, err := ParseStatus(status)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			session.Status = parsed.String()
+		}
+		if result := field(record, "result"); result != "" {
+			if result != Completed && result != Abandoned && result != Aborted {
+				return nil, fmt.Errorf("line %d: unknown result %q (expected %s, %s or %s)", line, result, Completed, Abandoned, Aborted)
+			}
+			session.Result = result
+		}
+
+		if end := field(record, "end"); end != "" {
+			if session.End, err = parseImportTime(end); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			session.Elapsed = Seconds(session.End.Sub(session.Start))
+		}
+		if elapsed := field(record, "elapsed"); elapsed != "" {
+			duration, err := time.ParseDuration(elapsed)
+			if seconds, numErr := strconv.ParseFloat(elapsed, 64); numErr == nil {
+				duration, err = time.Duration(seconds*float64(time.Second)), nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid elapsed time %q", line, elapsed)
+			}
+			session.Elapsed = Seconds(duration)
+			if session.End.IsZero() {
+				session.End = session.Start.Add(duration)
+			}
+		}
+		if session.End.IsZero() {
+			return nil, fmt.Errorf("line %d: missing the end or elapsed time", line)
+		}
+		sessions = append(sessions, session)
+	}
+}
+
+// parseImportTime parses a time in one of the importTimes formats
+func parseImportTime(value string) (time.Time, error) {
+	for _, layout := range importTimes {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (expected e.g. 2024-01-31T09:00:00+01:00 or 2024-01-31 09:00)", value)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Event names dispatched to the integrations
+const (
+	EventWorkStart = "work_start" // A work period started (possibly paused)
+	EventWorkEnd   = "work_end"   // A work period ended
+	EventRestStart = "rest_start" // A rest period started (possibly paused)
+	EventRestEnd   = "rest_end"   // A rest period ended
+	EventPause     = "pause"      // The timer was paused
+	EventResume    = "resume"     // The timer was resumed
+	EventTask      = "task"       // The current task changed
+	EventTimerEnd  = "timer_end"  // The timer of a period reached zero
+	EventUnblock   = "unblock"    // The blocker was lifted until the end of the work period
+	EventShutdown  = "shutdown"   // The daemon is exiting
+)
+
+// IntegrationTimeout bounds how long integrations may take to handle the shutdown event
+const IntegrationTimeout = 5 * time.Second
+
+// Event is a transition of the timer, along with the state right after it
+type Event struct {
+	Name      string
+	Time      time.Time
+	Status    PomodoroStatus
+	Paused    bool
+	Remaining time.Duration
+	Task      string
+	Unblocked bool // Whether the blocker is lifted until the end of the work period
+}
+
+// Running returns whether a period of the given status is counting down after the event
+func (event Event) Running(status PomodoroStatus) bool {
+	return event.Name != EventShutdown && event.Status == status && !event.Paused
+}
+
+// Class returns the name of the state after the event, as (*PomodoroState).Class
+func (event Event) Class() string {
+	if event.Paused {
+		return "paused"
+	}
+	return event.Status.String()
+}
+
+// Label returns the label of the state after the event, e.g. for templates
+func (event Event) Label() string {
+	return Label(event.Class())
+}
+
+// Integration reacts to the timer events, e.g. to track time in an external service
+type Integration interface {
+	Name() string
+	Handle(event Event) error
+}
+
+// Integrations dispatches the transitions of the state to every integration.
+// Each integration handles its events in order in its own goroutine, so slow
+// network calls never block the timer.
+type Integrations struct {
+	queues []chan Event
+	done   []chan struct{}
+
+	last        *Event    // State after the previous update
+	lastStarted time.Time // Start of the period at the previous update
+}
+
+// Add registers an integration and starts its worker
+func (integrations *Integrations) Add(integration Integration) {
+	queue, done := make(chan Event, 64), make(chan struct{})
+	integrations.queues = append(integrations.queues, queue)
+	integrations.done = append(integrations.done, done)
+
+	go func() {
+		defer close(done)
+		for event := range queue {
+			if err := integration.Handle(event); err != nil {
+				slog.Warn("running integration", "integration", integration.Name(), "event", event.Name, "err", err)
+			}
+		}
+	}()
+}
+
+// Name returns the name of the output
+func (integrations *Integrations) Name() string {
+	return "integrations"
+}
+
+// Refresh sends the events of the state changes, see Update
+func (integrations *Integrations) Refresh(state *PomodoroState) error {
+	integrations.Update(state)
+	return nil
+}
+
+// Update compares the state with the previous one and dispatches the resulting events
+func (integrations *Integrations) Update(state *PomodoroState) {
+	if len(integrations.queues) == 0 {
+		return
+	}
+
+	current := newEvent(state)
+	last, lastStarted := integrations.last, integrations.lastStarted
+	integrations.last, integrations.lastStarted = &current, state.Started
+
+	switch {
+	case last == nil:
+		integrations.dispatch(current, startEvent(state.Status))
+	case !state.Started.Equal(lastStarted):
+		integrations.dispatch(current, endEvent(last.Status))
+		integrations.dispatch(current, startEvent(state.Status))
+	case last.Paused != state.Paused && state.Paused:
+		integrations.dispatch(current, EventPause)
+	case last.Paused != state.Paused:
+		integrations.dispatch(current, EventResume)
+	}
+	if last != nil && last.Task != state.Task {
+		integrations.dispatch(current, EventTask)
+	}
+	if last != nil && !last.Unblocked && state.Unblocked {
+		integrations.dispatch(current, EventUnblock)
+	}
+}
+
+// TimerEnd dispatches the end of the timer of a period, with the status of
+// that period (the state may already be in the next one)
+func (integrations *Integrations) TimerEnd(status PomodoroStatus, state *PomodoroState) {
+	event := newEvent(state)
+	event.Status = status
+	integrations.dispatch(event, EventTimerEnd)
+}
+
+// Close dispatches the shutdown event and waits for the integrations to handle it
+func (integrations *Integrations) Close() {
+	if integrations.last != nil {
+		integrations.dispatch(*integrations.last, EventShutdown)
+	}
+	for _, queue := range integrations.queues {
+		close(queue)
+	}
+
+	timeout := time.After(IntegrationTimeout)
+	for _, done := range integrations.done {
+		select {
+		case <-done:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func (integrations *Integrations) dispatch(event Event, name string) {
+	event.Name = name
+	for _, queue := range integrations.queues {
+		select {
+		case queue <- event:
+		default:
+			slog.Warn("dropping integration event", "event", name)
+		}
+	}
+}
+
+// PeriodEndMessage returns the title and message of a notification about the
+// end of the timer, as used by the messaging integrations
+func PeriodEndMessage(event Event) (string, string) {
+	if event.Status == Work {
+		message := T("Time for a break.")
+		if event.Task != "" {
+			message = T("Time for a break from %s.", event.Task)
+		}
+		return labelOr(Rest, T("Work period over")), message
+	}
+	return labelOr(Work, T("Break over")), T("Back to work!")
+}
+
+// labelOr returns the custom label of the status, if any, or else the title
+func labelOr(status PomodoroStatus, title string) string {
+	if label, ok := Labels[status.String()]; ok {
+		return label
+	}
+	return title
+}
+
+// TransitionMessage describes the state change of an event for the messaging
+// integrations, or returns an empty string for the events not worth a message
+func TransitionMessage(event Event) string {
+	switch event.Name {
+	case EventWorkStart, EventRestStart:
+		if event.Paused {
+			return ""
+		} else if event.Status == Rest {
+			return T("Break started")
+		}
+		return T("Work period started")
+	case EventPause:
+		return T("Paused")
+	case EventResume:
+		return T("Resumed")
+	case EventTimerEnd:
+		title, message := PeriodEndMessage(event)
+		return title + ". " + message
+	}
+	return ""
+}
+
+func newEvent(state *PomodoroState) Event {
+	return Event{
+		Time:      time.Now(),
+		Status:    state.Status,
+		Paused:    state.Paused,
+		Remaining: state.Remaining(),
+		Task:      state.Task,
+		Unblocked: state.Unblocked,
+	}
+}
+
+func startEvent(status PomodoroStatus) string {
+	if status == Work {
+		return EventWorkStart
+	}
+	return EventRestStart
+}
+
+func endEvent(status PomodoroStatus) string {
+	if status == Work {
+		return EventWorkEnd
+	}
+	return EventRestEnd
+}
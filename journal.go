@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// JournalInterval is how often the journal is rewritten while the state doesn't change
+const JournalInterval = 10 * time.Second
+
+// Policies applied on startup to a period interrupted by an unclean shutdown
+const (
+	ResumeRestore = "restore" // Resume the period, counting the downtime as elapsed unless it was paused
+	ResumeAbort   = "abort"   // Record the period as aborted in the history and start afresh
+	ResumeOff     = "off"     // Don't keep a journal at all
+)
+
+// Journal keeps the state on disk so an interrupted period can be resumed
+// after a crash. The journal is marked clean on a normal shutdown.
+type Journal struct {
+	Path string
+
+	last    []byte
+	written time.Time
+}
+
+// journalEntry is the content of the journal file
+type journalEntry struct {
+	Status    string        `json:"status"`
+	Paused    bool          `json:"paused"`
+	End       time.Time     `json:"end,omitempty"`       // End of the period, while running
+	Remaining time.Duration `json:"remaining,omitempty"` // Remaining time, while paused
+	Started   time.Time     `json:"started"`
+	PausedAt  time.Time     `json:"paused_at"`
+	PausedFor time.Duration `json:"paused_for"`
+	Clean     bool          `json:"clean"`
+	Updated   time.Time     `json:"updated"`
+
+	Interruptions []string `json:"interruptions,omitempty"`
+	Notes         []string `json:"notes,omitempty"`
+
+	Task      string         `json:"task,omitempty"`
+	Estimates map[string]int `json:"estimates,omitempty"`
+}
+
+// Name returns the name of the output
+func (journal *Journal) Name() string {
+	return "journal"
+}
+
+// Refresh saves the state
+func (journal *Journal) Refresh(state *PomodoroState) error {
+	return journal.Save(state, false)
+}
+
+// Save writes the state to the journal if it changed, or if it hasn't been written for a while
+func (journal *Journal) Save(state *PomodoroState, clean bool) error {
+	entry := journalEntry{
+		Status:    state.Status.String(),
+		Paused:    state.Paused,
+		Started:   state.Started,
+		PausedAt:  state.PausedAt,
+		PausedFor: state.PausedFor,
+		Clean:     clean,
+
+		Interruptions: state.Interruptions,
+		Notes:         state.Notes,
+
+		Task:      state.Task,
+		Estimates: state.Estimates,
+	}
+	if state.Paused {
+		entry.Remaining = state.Remaining().Round(time.Second)
+	} else {
+		entry.End = state.End
+	}
+
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(content, journal.last) && time.Since(journal.written) < JournalInterval {
+		return nil
+	}
+
+	entry.Updated = time.Now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(DataDir(), 0o700); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(journal.Path, string(data)); err != nil {
+		return err
+	}
+	journal.last, journal.written = content, entry.Updated
+	return nil
+}
+
+// Resume applies the policy to the period left in the journal by an unclean
+// shutdown, if any
+func (journal *Journal) Resume(state *PomodoroState, policy string) error {
+	data, err := os.ReadFile(journal.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var entry journalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return fmt.Errorf("invalid journal %s: %w", journal.Path, err)
+	}
+	// The task is restored even after a clean shutdown, as it spans several periods
+	state.Task, state.Estimates = entry.Task, entry.Estimates
+	if entry.Clean {
+		return nil
+	}
+	status, err := ParseStatus(entry.Status)
+	if err != nil {
+		return err
+	}
+
+	if policy == ResumeAbort {
+		// The period ended when the journal was last written
+		elapsed := entry.Updated.Sub(entry.Started) - entry.PausedFor
+		if entry.Paused {
+			elapsed -= entry.Updated.Sub(entry.PausedAt)
+		}
+		if state.History == nil {
+			return nil
+		}
+		session := Session{
+			Start:   entry.Started,
+			End:     entry.Updated,
+			Status:  entry.Status,
+			Elapsed: Seconds(max(0, elapsed)),
+			Result:  Aborted,
+
+			Interruptions: entry.Interruptions,
+			Notes:         entry.Notes,
+		}
+		if status == Work {
+			session.Task, session.Estimate = entry.Task, entry.Estimates[entry.Task]
+		}
+		return state.History.Append(session)
+	}
+
+	remaining := entry.Remaining
+	if !entry.Paused {
+		remaining = entry.End.Sub(time.Now())
+	}
+	state.Set(status, entry.Paused, remaining)
+	state.Started, state.PausedAt, state.PausedFor = entry.Started, entry.PausedAt, entry.PausedFor
+	state.Interruptions = entry.Interruptions
+	state.Notes = entry.Notes
+	if remaining <= 0 && !state.Overtime {
+		// The period ended while the daemon was down: start the next one paused
+		state.Toggle()
+		if !state.Paused {
+			state.Pause()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// DefaultLightColors are the colours of the states, as hex RGB
+const DefaultLightColors = "work=ff0000,rest=00ff00,paused=ffaa00"
+
+// ParseColors parses a comma-separated list of class=rrggbb pairs
+func ParseColors(list string) (map[string][3]byte, error) {
+	colors := make(map[string][3]byte)
+	for class, value := range ParseMapping(list) {
+		rgb, err := hex.DecodeString(value)
+		if err != nil || len(rgb) != 3 {
+			return nil, fmt.Errorf("invalid colour %q for %q", value, class)
+		}
+		colors[class] = [3]byte(rgb)
+	}
+	return colors, nil
+}
+
+// LightBackend controls a smart light
+type LightBackend interface {
+	Get() (json.RawMessage, error) // State of the light, to restore it later
+	Set(color [3]byte) error       // Turn the light on with the colour
+	Restore(state json.RawMessage) error
+}
+
+// Light changes the colour of a smart light on each transition, by state, and
+// restores the light as it was before on exit
+type Light struct {
+	Backend LightBackend
+	Colors  map[string][3]byte // Colour by class (work, rest or paused)
+
+	saved json.RawMessage // State of the light before the first change
+}
+
+// NewLight creates the integration for a light address, either
+// wled://<host> or hue://<bridge>/<username>/<light id>
+func NewLight(addr string, colors string) (*Light, error) {
+	light := &Light{}
+	var err error
+	if light.Colors, err = ParseColors(colors); err != nil {
+		return nil, err
+	}
+
+	scheme, address, _ := strings.Cut(addr, "://")
+	client := &http.Client{Timeout: IntegrationTimeout}
+	switch scheme {
+	case "wled":
+		light.Backend = &WLED{URL: "http://" + address + "/json/state", client: client}
+	case "hue":
+		parts := strings.Split(address, "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid hue light %q (expected hue://<bridge>/<username>/<light id>)", addr)
+		}
+		light.Backend = &Hue{URL: fmt.Sprintf("http://%s/api/%s/lights/%s", parts[0], parts[1], parts[2]), client: client}
+	default:
+		return nil, fmt.Errorf("invalid light %q (expected wled:// or hue://)", addr)
+	}
+	return light, nil
+}
+
+// Name returns the name of the integration
+func (light *Light) Name() string {
+	return "light"
+}
+
+// Handle sets the colour of the state after the event, or restores the light on shutdown
+func (light *Light) Handle(event Event) error {
+	if event.Name == EventShutdown {
+		if light.saved == nil {
+			return nil
+		}
+		return light.Backend.Restore(light.saved)
+	}
+
+	color, ok := light.Colors[event.Class()]
+	if event.Name == EventTask || !ok {
+		return nil
+	}
+	if light.saved == nil {
+		saved, err := light.Backend.Get()
+		if err != nil {
+			return err
+		}
+		light.saved = saved
+	}
+	return light.Backend.Set(color)
+}
+
+// WLED controls a WLED device through its JSON API
+type WLED struct {
+	URL string
+
+	client *http.Client
+}
+
+// Get returns the state of the device
+func (wled *WLED) Get() (json.RawMessage, error) {
+	return lightRequest(wled.client, http.MethodGet, wled.URL, nil)
+}
+
+// Set turns the device on with the colour as the primary colour of its main segment
+func (wled *WLED) Set(color [3]byte) error {
+	body := map[string]any{
+		"on":  true,
+		"seg": []map[string]any{{"col": [][3]int{{int(color[0]), int(color[1]), int(color[2])}}}},
+	}
+	_, err := lightRequest(wled.client, http.MethodPost, wled.URL, body)
+	return err
+}
+
+// Restore sends back a state returned by Get
+func (wled *WLED) Restore(state json.RawMessage) error {
+	_, err := lightRequest(wled.client, http.MethodPost, wled.URL, state)
+	return err
+}
+
+// Hue controls a light of a Philips Hue bridge through its v1 API
+type Hue struct {
+	URL string
+
+	client *http.Client
+}
+
+// Get returns the state of the light
+func (hue *Hue) Get() (json.RawMessage, error) {
+	data, err := lightRequest(hue.client, http.MethodGet, hue.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var light struct {
+		State map[string]any `json:"state"`
+	}
+	if err := json.Unmarshal(data, &light); err != nil {
+		return nil, err
+	}
+
+	// Only the settable attributes of the active colour mode are kept
+	state := map[string]any{"on": light.State["on"], "bri": light.State["bri"]}
+	switch light.State["colormode"] {
+	case "xy":
+		state["xy"] = light.State["xy"]
+	case "ct":
+		state["ct"] = light.State["ct"]
+	case "hs":
+		state["hue"], state["sat"] = light.State["hue"], light.State["sat"]
+	}
+	for key, value := range state {
+		if value == nil {
+			delete(state, key)
+		}
+	}
+	return json.Marshal(state)
+}
+
+// Set turns the light on with the colour, converted to CIE xy coordinates
+func (hue *Hue) Set(color [3]byte) error {
+	x, y := rgbToXY(color)
+	body := map[string]any{"on": true, "bri": 254, "xy": []float64{x, y}}
+	_, err := lightRequest(hue.client, http.MethodPut, hue.URL+"/state", body)
+	return err
+}
+
+// Restore sends back a state returned by Get
+func (hue *Hue) Restore(state json.RawMessage) error {
+	_, err := lightRequest(hue.client, http.MethodPut, hue.URL+"/state", state)
+	return err
+}
+
+// rgbToXY converts a sRGB colour to CIE xy chromaticity coordinates
+func rgbToXY(color [3]byte) (float64, float64) {
+	var linear [3]float64
+	for i, c := range color {
+		v := float64(c) / 255
+		if v > 0.04045 {
+			linear[i] = math.Pow((v+0.055)/1.055, 2.4)
+		} else {
+			linear[i] = v / 12.92
+		}
+	}
+	r, g, b := linear[0], linear[1], linear[2]
+	x := r*0.4124 + g*0.3576 + b*0.1805
+	y := r*0.2126 + g*0.7152 + b*0.0722
+	z := r*0.0193 + g*0.1192 + b*0.9505
+	if x+y+z == 0 {
+		return 0.3127, 0.3290 // White point
+	}
+	return x / (x + y + z), y / (x + y + z)
+}
+
+func lightRequest(client *http.Client, method, url string, body any) (json.RawMessage, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: %s", method, url, response.Status)
+	}
+	return data, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SetupLogging installs the default structured logger, writing to stderr and,
+// when path is not empty, appending to a log file as well. The log file is
+// rotated once it grows past maxSize bytes (0 means unlimited). The returned
+// closer releases the log file.
+func SetupLogging(level, path string, maxSize int64) (io.Closer, error) {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	var output io.Writer = os.Stderr
+	var file *RotatingFile
+	if path != "" {
+		var err error
+		if file, err = OpenRotatingFile(path, maxSize); err != nil {
+			return nil, err
+		}
+		output = io.MultiWriter(os.Stderr, file)
+	}
+
+	handler := slog.NewTextHandler(output, &slog.HandlerOptions{Level: logLevel})
+	slog.SetDefault(slog.New(handler))
+	if file == nil {
+		return io.NopCloser(nil), nil
+	}
+	return file, nil
+}
+
+// RotatingFile is an append-only file that is moved to path.1 (replacing the
+// previous backup) once it grows past MaxSize bytes, so long-running daemons
+// don't slowly fill the disk
+type RotatingFile struct {
+	Path    string
+	MaxSize int64
+
+	file  *os.File
+	size  int64
+	mutex sync.Mutex
+}
+
+// OpenRotatingFile opens the file for appending, rotating it right away if it is already too big
+func OpenRotatingFile(path string, maxSize int64) (*RotatingFile, error) {
+	rotating := &RotatingFile{Path: path, MaxSize: maxSize}
+	if err := rotating.open(); err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && rotating.size >= maxSize {
+		if err := rotating.rotate(); err != nil {
+			rotating.file.Close()
+			return nil, err
+		}
+	}
+	return rotating, nil
+}
+
+// Write appends p to the file, rotating it first if p would exceed the size cap
+func (rotating *RotatingFile) Write(p []byte) (int, error) {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+
+	if rotating.MaxSize > 0 && rotating.size > 0 && rotating.size+int64(len(p)) > rotating.MaxSize {
+		if err := rotating.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rotating.file.Write(p)
+	rotating.size += int64(n)
+	return n, err
+}
+
+// Close closes the underlying file
+func (rotating *RotatingFile) Close() error {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	return rotating.file.Close()
+}
+
+func (rotating *RotatingFile) open() error {
+	file, err := os.OpenFile(rotating.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rotating.file, rotating.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current file to the backup path and starts a new one. If
+// the file can't be moved, logging keeps appending to it rather than stopping.
+func (rotating *RotatingFile) rotate() error {
+	rotating.file.Close()
+	os.Rename(rotating.Path, rotating.Path+".1")
+	return rotating.open()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall"
+)
+
+// Output is a consumer of the state, refreshed by the main loop after every
+// event that changed it
+type Output interface {
+	Name() string
+	Refresh(state *PomodoroState) error
+}
+
+// Renders receives the render requests of the goroutines changing what the
+// output shows outside of the main loop (e.g. the degraded flag)
+var Renders = make(chan struct{}, 1)
+
+// RequestRender asks the main loop to refresh the outputs, without blocking
+// when a request is already pending
+func RequestRender() {
+	select {
+	case Renders <- struct{}{}:
+	default:
+	}
+}
+
+// LineOutput prints the rendered state line by line on stdout, or into a named pipe
+type LineOutput struct {
+	Render Renderer
+	FIFO   *FIFO // Written instead of stdout when set
+
+	last   string
+	closed bool // Whether the reader of stdout went away
+}
+
+// Name returns the name of the output
+func (output *LineOutput) Name() string {
+	return "line"
+}
+
+// Refresh prints the state. In minutes mode, unchanged lines are skipped to
+// spare bar redraws.
+func (output *LineOutput) Refresh(state *PomodoroState) error {
+	if output.closed {
+		return nil
+	}
+	line := output.Render(state)
+	if state.MinutesOnly && line == output.last {
+		return nil
+	}
+	output.last = line
+	if output.FIFO != nil {
+		output.FIFO.Write(line)
+		return nil
+	}
+	// SIGPIPE is ignored, so a bar that exits or restarts shows up as EPIPE:
+	// the timer keeps running for the other outputs, the clients and the
+	// next bar, which can follow it with the subscribe subcommand
+	_, err := fmt.Fprintln(os.Stdout, line)
+	if errors.Is(err, syscall.EPIPE) {
+		output.closed = true
+		slog.Warn("stdout was closed by its reader, continuing without printing updates")
+		return nil
+	}
+	return err
+}
+
+// Notifier shows the desktop notification of the last period end, deferred
+// while a window is fullscreen
+type Notifier struct {
+	Title, Message string
+}
+
+// Name returns the name of the output
+func (notifier *Notifier) Name() string {
+	return "notifications"
+}
+
+// Refresh shows the pending notification, the unlocked achievements and the
+// due reminders, unless a window is fullscreen
+func (notifier *Notifier) Refresh(state *PomodoroState) error {
+	if state.Fullscreen {
+		return nil
+	}
+	if notifier.Message != "" {
+		Notify(notifier.Title, notifier.Message)
+		notifier.Title, notifier.Message = "", ""
+	}
+	for _, achievement := range state.Unlocked {
+		Notify(T("Achievement unlocked: %s", T(achievement.Name)), achievement.Describe())
+	}
+	state.Unlocked = nil
+	for _, reminder := range state.Reminders {
+		if reminder.Due && !reminder.Notified {
+			Notify(T("Reminder"), reminder.Name)
+			reminder.Notified = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Matrix sends the state changes as notices to a Matrix room, as a bot user
+type Matrix struct {
+	Homeserver string // e.g. https://matrix.org
+	Room       string // Room id, e.g. !abc:matrix.org
+	Token      string // Access token of the bot user
+
+	client *http.Client
+	txn    int // Counter of the transaction ids, unique in this run
+}
+
+// NewMatrix creates the integration sending to the room of the homeserver
+func NewMatrix(homeserver, room, token string) *Matrix {
+	return &Matrix{
+		Homeserver: strings.TrimSuffix(homeserver, "/"),
+		Room:       room,
+		Token:      token,
+		client:     &http.Client{Timeout: IntegrationTimeout},
+	}
+}
+
+// Name returns the name of the integration
+func (matrix *Matrix) Name() string {
+	return "matrix"
+}
+
+// Handle sends a notice about the state change
+func (matrix *Matrix) Handle(event Event) error {
+	text := TransitionMessage(event)
+	if text == "" {
+		return nil
+	}
+
+	// Transaction ids make retried requests idempotent
+	matrix.txn++
+	txn := fmt.Sprintf("polybar-pomo-%d-%d", time.Now().UnixNano(), matrix.txn)
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		matrix.Homeserver, url.PathEscape(matrix.Room), txn)
+
+	body, err := json.Marshal(map[string]string{"msgtype": "m.notice", "body": text})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+matrix.Token)
+
+	response, err := matrix.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("sending to %s: %s: %s", matrix.Room, response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
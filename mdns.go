@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ServiceType is the DNS-SD service type of the network listeners
+const ServiceType = "_polybar-pomo._tcp"
+
+// Advertise runs avahi-publish, which registers the listener on port with the
+// avahi daemon so clients on the LAN find it over mDNS. The TXT record tells
+// the protocol of the listener (tcp for commands, http for the HTTP API) and
+// whether a token is required. It returns when avahi-publish exits.
+func Advertise(protocol string, port int, tokenRequired bool) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("polybar-pomo %s on %s", protocol, hostname)
+	txt := []string{"proto=" + protocol, fmt.Sprintf("token=%t", tokenRequired)}
+	if protocol == "http" {
+		txt = append(txt, "path=/events")
+	}
+
+	cmd := exec.Command("avahi-publish", append([]string{"-s", name, ServiceType, fmt.Sprint(port)}, txt...)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM} // Don't outlive the daemon
+	slog.Info("advertising over mDNS", "name", name, "type", ServiceType, "port", port)
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+	}
+	return err
+}
+
+// AdvertisedPort returns the port of a listener address to advertise, warning
+// when it is only reachable from the local host
+func AdvertisedPort(addr string) (int, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, err
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		slog.Warn("advertising a listener unreachable from the LAN", "addr", addr)
+	}
+	return net.LookupPort("tcp", port)
+}
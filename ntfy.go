@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Ntfy publishes push notifications to an ntfy topic
+type Ntfy struct {
+	URL   string // Topic URL, e.g. https://ntfy.sh/mytopic
+	Token string // Access token, for protected topics
+}
+
+// Push publishes the notification
+func (ntfy *Ntfy) Push(title, message string) error {
+	request, err := http.NewRequest(http.MethodPost, ntfy.URL, strings.NewReader(message))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Title", title)
+	request.Header.Set("Tags", "tomato")
+	if ntfy.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+ntfy.Token)
+	}
+	return pushRequest(request)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// OpenRGB SDK packet ids
+const (
+	openRGBControllerCount = 0
+	openRGBControllerData  = 1
+	openRGBClientName      = 50
+	openRGBUpdateLEDs      = 1050
+	openRGBCustomMode      = 1100
+)
+
+// OpenRGB sets the colour of every LED of the devices managed by an OpenRGB
+// server (through its SDK protocol) after each transition, by state
+type OpenRGB struct {
+	Addr   string
+	Colors map[string][3]byte // Colour by class (work, rest or paused)
+}
+
+// NewOpenRGB creates the integration, parsing colors as a comma-separated
+// list of class=rrggbb pairs
+func NewOpenRGB(addr string, colors string) (*OpenRGB, error) {
+	parsed, err := ParseColors(colors)
+	if err != nil {
+		return nil, err
+	}
+	return &OpenRGB{Addr: addr, Colors: parsed}, nil
+}
+
+// Name returns the name of the integration
+func (openRGB *OpenRGB) Name() string {
+	return "openrgb"
+}
+
+// Handle sets the colour of the state after the event
+func (openRGB *OpenRGB) Handle(event Event) error {
+	color, ok := openRGB.Colors[event.Class()]
+	if event.Name == EventShutdown || event.Name == EventTask || !ok {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", openRGB.Addr, PeerTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(IntegrationTimeout))
+
+	if err := openRGBSend(conn, 0, openRGBClientName, []byte("polybar-pomo\x00")); err != nil {
+		return err
+	}
+	if err := openRGBSend(conn, 0, openRGBControllerCount, nil); err != nil {
+		return err
+	}
+	reply, err := openRGBReceive(conn, openRGBControllerCount)
+	if err != nil || len(reply) < 4 {
+		return errors.Join(errors.New("reading controller count"), err)
+	}
+
+	devices := binary.LittleEndian.Uint32(reply)
+	for device := uint32(0); device < devices; device++ {
+		if err := openRGBSend(conn, device, openRGBControllerData, nil); err != nil {
+			return err
+		}
+		data, err := openRGBReceive(conn, openRGBControllerData)
+		if err != nil {
+			return err
+		}
+		leds, err := openRGBColorCount(data)
+		if err != nil {
+			return fmt.Errorf("device %d: %w", device, err)
+		}
+
+		update := binary.LittleEndian.AppendUint32(nil, uint32(6+4*leds))
+		update = binary.LittleEndian.AppendUint16(update, leds)
+		for i := uint16(0); i < leds; i++ {
+			update = append(update, color[0], color[1], color[2], 0)
+		}
+		if err := openRGBSend(conn, device, openRGBCustomMode, nil); err != nil {
+			return err
+		}
+		if err := openRGBSend(conn, device, openRGBUpdateLEDs, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func openRGBSend(conn net.Conn, device uint32, id uint32, data []byte) error {
+	packet := []byte("ORGB")
+	packet = binary.LittleEndian.AppendUint32(packet, device)
+	packet = binary.LittleEndian.AppendUint32(packet, id)
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(len(data)))
+	_, err := conn.Write(append(packet, data...))
+	return err
+}
+
+func openRGBReceive(conn net.Conn, id uint32) ([]byte, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "ORGB" {
+		return nil, errors.New("invalid openrgb packet")
+	}
+	if got := binary.LittleEndian.Uint32(header[8:]); got != id {
+		return nil, fmt.Errorf("unexpected openrgb packet %d", got)
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header[12:]))
+	_, err := io.ReadFull(conn, data)
+	return data, err
+}
+
+// openRGBColorCount returns the number of colours (one per LED) of a device,
+// from its controller data in the version 0 protocol
+func openRGBColorCount(data []byte) (uint16, error) {
+	reader := bytes.NewReader(data)
+	var err error
+	skip := func(n int64) {
+		if err == nil {
+			_, err = reader.Seek(n, io.SeekCurrent)
+		}
+	}
+	count := func() uint16 {
+		var n uint16
+		if err == nil {
+			err = binary.Read(reader, binary.LittleEndian, &n)
+		}
+		return n
+	}
+	skipString := func() { skip(int64(count())) }
+
+	skip(8) // Data size and device type
+	for i := 0; i < 5; i++ {
+		skipString() // Name, description, version, serial and location
+	}
+	modes := count()
+	skip(4) // Active mode
+	for i := uint16(0); i < modes; i++ {
+		skipString()
+		skip(9 * 4) // Value, flags, speeds, colour counts, speed, direction and colour mode
+		skip(4 * int64(count()))
+	}
+	zones := count()
+	for i := uint16(0); i < zones; i++ {
+		skipString()
+		skip(4 * 4) // Type and LED counts
+		skip(int64(count()))
+	}
+	leds := count()
+	for i := uint16(0); i < leds; i++ {
+		skipString()
+		skip(4) // Value
+	}
+	colors := count()
+	if err != nil {
+		return 0, fmt.Errorf("invalid controller data: %w", err)
+	}
+	return colors, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// OverlayArgs are the yad options of the fullscreen windows covering the screen
+var OverlayArgs = []string{"--fullscreen", "--undecorated", "--on-top", "--sticky", "--skip-taskbar", "--no-escape", "--center", "--title=polybar-pomo"}
+
+// Overlay covers the screen during breaks with a fullscreen yad window
+// showing the countdown, for the breaks not to be ignored. Its skip button
+// (or closing the window) ends the break early.
+type Overlay struct {
+	Commands chan Command // Main loop receiving the skip command
+
+	window *overlayWindow
+}
+
+// overlayWindow is a running yad window and the goroutine updating it
+type overlayWindow struct {
+	cmd     *exec.Cmd
+	updates chan Event    // Latest event of the break, e.g. paused
+	hidden  chan struct{} // Closed when the break ends, before stopping yad
+}
+
+// Name returns the name of the integration
+func (overlay *Overlay) Name() string {
+	return "overlay"
+}
+
+// Handle shows the overlay on a running rest period, updates it during the
+// rest period, and hides it on work or shutdown
+func (overlay *Overlay) Handle(event Event) error {
+	switch {
+	case overlay.window == nil && event.Running(Rest):
+		window, err := showOverlay(event, overlay.Commands)
+		overlay.window = window
+		return err
+	case overlay.window != nil && (event.Status == Work || event.Name == EventShutdown):
+		overlay.window.hide()
+		overlay.window = nil
+	case overlay.window != nil:
+		select {
+		case <-overlay.window.updates: // Replaced by the latest event
+		default:
+		}
+		overlay.window.updates <- event
+	}
+	return nil
+}
+
+// showOverlay starts yad, whose progress bar counts the break down, and
+// sends the skip command once the user closes it
+func showOverlay(event Event, commands chan Command) (*overlayWindow, error) {
+	args := append([]string{"--progress", "--text=" + T("Step away from the screen."), "--button=" + T("Skip") + ":0"}, OverlayArgs...)
+	cmd := exec.Command("yad", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM} // Don't outlive the daemon
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting yad: %w", err)
+	}
+
+	window := &overlayWindow{cmd: cmd, updates: make(chan Event, 1), hidden: make(chan struct{})}
+	go window.countdown(event, stdin)
+	go func() {
+		cmd.Wait()
+		select {
+		case <-window.hidden:
+		default:
+			commands <- Command{Name: "skip"}
+		}
+	}()
+	return window, nil
+}
+
+// countdown writes the progress and the remaining time to yad every second
+func (window *overlayWindow) countdown(event Event, stdin io.WriteCloser) {
+	defer stdin.Close()
+	total := event.Remaining
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		remaining := event.Remaining
+		if !event.Paused {
+			remaining -= time.Since(event.Time)
+		}
+		total = max(total, remaining)
+		text := formatClock(remaining)
+		if event.Paused {
+			text = T("Paused · %s left", formatClock(remaining))
+		}
+		percent := 100
+		if total > 0 {
+			percent = min(100, max(0, int(100*(total-remaining)/total)))
+		}
+		if _, err := fmt.Fprintf(stdin, "%d\n#%s\n", percent, text); err != nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case event = <-window.updates:
+		case <-window.hidden:
+			return
+		}
+	}
+}
+
+// hide closes the window without skipping the break
+func (window *overlayWindow) hide() {
+	close(window.hidden)
+	window.cmd.Process.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// AllowedUIDs are the users allowed to send commands over the Unix socket
+var AllowedUIDs = map[int]bool{os.Getuid(): true}
+
+// AllowedGroup is the group whose members may also send commands over the
+// Unix socket, -1 for none
+var AllowedGroup = -1
+
+// ParseUIDs parses a comma-separated list of user ids
+func ParseUIDs(list string) (map[int]bool, error) {
+	uids := make(map[int]bool)
+	for _, field := range strings.Split(list, ",") {
+		uid, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid user id %q", field)
+		}
+		uids[uid] = true
+	}
+	return uids, nil
+}
+
+// ParseSocketMode parses the octal permission bits of the socket file, e.g. 0660
+func ParseSocketMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q (expected octal permissions, e.g. 0660)", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// LookupGroup returns the id of a group given by name or id
+func LookupGroup(name string) (int, error) {
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		if group, err = user.LookupGroupId(name); err != nil {
+			return 0, fmt.Errorf("unknown group %q", name)
+		}
+	}
+	return strconv.Atoi(group.Gid)
+}
+
+// peerCred returns the SO_PEERCRED credentials of the process at the other
+// end of a Unix socket connection
+func peerCred(conn *net.UnixConn) (*syscall.Ucred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, credErr
+}
+
+// PeerUID returns the user id of the process at the other end of a Unix
+// socket connection
+func PeerUID(conn *net.UnixConn) (int, error) {
+	cred, err := peerCred(conn)
+	if err != nil {
+		return 0, err
+	}
+	return int(cred.Uid), nil
+}
+
+// inGroup returns whether the process of the credentials runs with the
+// group, or its user is a member of it
+func inGroup(cred *syscall.Ucred, gid int) bool {
+	if int(cred.Gid) == gid {
+		return true
+	}
+	account, err := user.LookupId(strconv.Itoa(int(cred.Uid)))
+	if err != nil {
+		return false
+	}
+	groups, err := account.GroupIds()
+	if err != nil {
+		return false
+	}
+	for _, group := range groups {
+		if group == strconv.Itoa(gid) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPeer returns an error unless the peer of a Unix socket connection is
+// one of the AllowedUIDs or a member of the AllowedGroup. Other connections
+// are authenticated by their token instead.
+func CheckPeer(conn net.Conn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+	cred, err := peerCred(unixConn)
+	if err != nil {
+		return fmt.Errorf("reading peer credentials: %w", err)
+	} else if !AllowedUIDs[int(cred.Uid)] && (AllowedGroup < 0 || !inGroup(cred, AllowedGroup)) {
+		return fmt.Errorf("user id %d is not allowed", cred.Uid)
+	}
+	return nil
+}
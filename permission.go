@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permission is what the commands of a connection may do
+type Permission int
+
+const (
+	ReadOnly    Permission = iota // Only query and subscribe to the state
+	FullControl                   // Also change the timer
+)
+
+// ReadOnlyCommands are the commands allowed on read-only connections
+var ReadOnlyCommands = map[string]bool{"join": true, "subscribe": true}
+
+// ParsePermission parses a permission name: control or read
+func ParsePermission(name string) (Permission, error) {
+	switch strings.ToLower(name) {
+	case "control":
+		return FullControl, nil
+	case "read":
+		return ReadOnly, nil
+	}
+	return ReadOnly, fmt.Errorf("unknown permission %q (expected control or read)", name)
+}
+
+// Allows returns whether the permission covers the command, and every
+// command of a batch
+func (permission Permission) Allows(cmd Command) bool {
+	if permission == FullControl {
+		return true
+	}
+	for _, command := range cmd.Batch {
+		if !ReadOnlyCommands[command.Name] {
+			return false
+		}
+	}
+	return cmd.Name == "" || cmd.Name == "batch" || ReadOnlyCommands[cmd.Name]
+}
+
+// Access controls the connections of a listener
+type Access struct {
+	Token      string     // Shared token required to prefix the commands, when set
+	ReadToken  string     // Shared token granting read-only access, when set
+	Permission Permission // Permission of the connections authenticated with Token
+}
+
+// Authenticate checks the token prefixing the message, returning the command
+// that follows it and the permission it grants. Clients without a token are
+// only let in when no token is set: with a read token alone, the listener
+// is read-only.
+func (access Access) Authenticate(message string) (string, Permission, bool) {
+	if access.ReadToken != "" {
+		if command, ok := Authenticate(message, access.ReadToken); ok {
+			return command, ReadOnly, true
+		} else if access.Token == "" {
+			return "", ReadOnly, false
+		}
+	}
+	command, ok := Authenticate(message, access.Token)
+	return command, access.Permission, ok
+}
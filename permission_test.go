@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestPermissionAllows(t *testing.T) {
+	batch := func(names ...string) Command {
+		cmd := Command{Name: "batch"}
+		for _, name := range names {
+			cmd.Batch = append(cmd.Batch, Command{Name: name})
+		}
+		return cmd
+	}
+	tests := []struct {
+		permission Permission
+		cmd        Command
+		want       bool
+	}{
+		{FullControl, Command{Name: "toggle"}, true},
+		{FullControl, batch("subscribe", "toggle"), true},
+		{ReadOnly, Command{Name: "subscribe"}, true},
+		{ReadOnly, Command{Name: "join"}, true},
+		{ReadOnly, Command{}, true}, // Empty line
+		{ReadOnly, Command{Name: "toggle"}, false},
+		{ReadOnly, Command{Name: "reset"}, false},
+		{ReadOnly, batch("subscribe", "join"), true},
+		{ReadOnly, batch("subscribe", "toggle"), false},
+	}
+	for _, test := range tests {
+		if got := test.permission.Allows(test.cmd); got != test.want {
+			t.Errorf("%d.Allows(%+v) = %t, want %t", test.permission, test.cmd, got, test.want)
+		}
+	}
+}
+
+func TestParsePermission(t *testing.T) {
+	tests := []struct {
+		name string
+		want Permission
+		err  bool
+	}{
+		{"control", FullControl, false},
+		{"Read", ReadOnly, false},
+		{"write", ReadOnly, true},
+	}
+	for _, test := range tests {
+		got, err := ParsePermission(test.name)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("ParsePermission(%q) = %d, %v, want %d, error %t", test.name, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestAccessAuthenticate(t *testing.T) {
+	tests := []struct {
+		name       string
+		access     Access
+		message    string
+		command    string
+		permission Permission
+		ok         bool
+	}{
+		{"no token", Access{Permission: FullControl}, "toggle", "toggle", FullControl, true},
+		{"token", Access{Token: "secret", Permission: FullControl}, "secret toggle", "toggle", FullControl, true},
+		{"wrong token", Access{Token: "secret", Permission: FullControl}, "guess toggle", "", FullControl, false},
+		{"missing token", Access{Token: "secret", Permission: FullControl}, "toggle", "", FullControl, false},
+		{"read token", Access{Token: "secret", ReadToken: "public", Permission: FullControl}, "public subscribe", "subscribe", ReadOnly, true},
+		{"control token", Access{Token: "secret", ReadToken: "public", Permission: FullControl}, "secret toggle", "toggle", FullControl, true},
+		{"read-only listener", Access{Token: "secret", ReadToken: "public", Permission: ReadOnly}, "secret subscribe", "subscribe", ReadOnly, true},
+		{"read token alone", Access{ReadToken: "public", Permission: FullControl}, "public subscribe", "subscribe", ReadOnly, true},
+		{"no token with a read token alone", Access{ReadToken: "public", Permission: FullControl}, "toggle", "", ReadOnly, false},
+		{"wrong token with a read token alone", Access{ReadToken: "public", Permission: FullControl}, "guess toggle", "", ReadOnly, false},
+	}
+	for _, test := range tests {
+		command, permission, ok := test.access.Authenticate(test.message)
+		if command != test.command || permission != test.permission || ok != test.ok {
+			t.Errorf("%s: Authenticate(%q) = %q, %d, %t, want %q, %d, %t", test.name, test.message,
+				command, permission, ok, test.command, test.permission, test.ok)
+		}
+	}
+}
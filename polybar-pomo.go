@@ -1,25 +1,50 @@
 package main
 
 import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
 const (
-	TomatoEmoji = "\U0001F345"        // Emoji representation for work status
-	RestEmoji   = "\U0001F3D6"        // Emoji representation for rest status
-	PauseEmoji  = "\U000023F8"        // Emoji representation for pause status
-	SocketPath  = "/tmp/polybar-pomo" // Unix socket path
+	TomatoEmoji = "\U0001F345" // Emoji representation for work status
+	RestEmoji   = "\U0001F3D6" // Emoji representation for rest status
+	PauseEmoji  = "\U000023F8" // Emoji representation for pause status
+	WarnEmoji   = "\U000026A0" // Emoji representation for degraded control sockets
+
+	LegacySocketPath = "/tmp/polybar-pomo" // Unix socket path of older versions, linked to the socket
+
+	DefaultStep   = 5 * time.Second // Default amount added or removed by the inc and dec commands
+	DefaultSnooze = 5 * time.Minute // Default delay of the snooze command
+	NoteGrace     = 2 * time.Minute // How long notes are attached to the previous period after it ended
+
+	AcceptBackoff    = 5 * time.Millisecond // Initial delay before accepting again after an error
+	MaxAcceptBackoff = 1 * time.Second      // Maximum delay before accepting again after an error
 )
 
 var (
 	WorkDuration time.Duration
 	RestDuration time.Duration
+	SocketPath   = DefaultSocketPath() // Unix socket path, set with -socket
+	Degraded     atomic.Bool           // Set while a listener fails to accept connections
 )
 
 // PomodoroStatus represents the status of the pomodoro timer
@@ -30,24 +55,118 @@ const (
 	Rest
 )
 
+// String returns the name of the pomodoro status
+func (status PomodoroStatus) String() string {
+	if status == Rest {
+		return "rest"
+	}
+	return "work"
+}
+
+// ParseStatus returns the pomodoro status with the given name
+func ParseStatus(name string) (PomodoroStatus, error) {
+	switch strings.ToLower(name) {
+	case "work":
+		return Work, nil
+	case "rest":
+		return Rest, nil
+	}
+	return Work, fmt.Errorf("invalid status %q (expected work or rest)", name)
+}
+
 // PomodoroState holds the state of the pomodoro timer
 type PomodoroState struct {
 	End    time.Time
+	Frozen time.Time // While paused, the instant End is relative to, see Remaining
 	Paused bool
 	Status PomodoroStatus
 	Ticker *time.Ticker
 	Timer  *time.Timer
+
+	Started   time.Time     // When the current period started
+	PausedAt  time.Time     // When the timer was last paused
+	PausedFor time.Duration // Time spent paused in the current period, excluding the ongoing pause
+	History   *History      // Records the finished periods, when enabled
+	Peers     *Peers        // Receive the recorded periods, merged into their history
+	Deferred  []func()      // Changes of the history waiting for the batch being applied to succeed, see persist
+
+	Overtime  bool    // Keep counting past the end of a period instead of switching to the next one
+	AutoStart [2]bool // Whether periods of each status start running when the previous one ends
+
+	MinWork      time.Duration // Time into a work period before toggle is accepted
+	MaxExtension time.Duration // Maximum time added to a period with inc, 0 for unlimited
+	MinRemaining time.Duration // Minimum remaining time dec can leave
+	Extended     time.Duration // Time added (or removed) with inc and dec in the current period
+	Ended        bool          // Whether the end of the current period was reached (and notified) in overtime mode
+
+	Interruptions []string  // Reasons of the interruptions of the current period
+	Notes         []string  // Notes taken about the current period
+	LastRecorded  time.Time // When a period was last recorded in the history
+
+	Task      string         // Label of the task being worked on, kept across periods
+	Estimates map[string]int // Estimated number of pomodoros by task label
+
+	Achievements bool          // Notify the achievements unlocked by completed work periods
+	Unlocked     []Achievement // Unlocked achievements waiting to be notified
+
+	ShowToday bool   // Display the number of work periods completed today
+	Today     int    // Work periods completed on TodayDate, counted when the history is enabled
+	TodayDate string // Day of the Today count
+	Goal      int    // Work periods to complete in a day, shown along with Today (0 for none)
+
+	Views []string // Display views cycled through with the view command
+	View  int      // Index of the current view
+	Width int      // Minimum width of the output, padded with spaces
+
+	MinutesOnly bool // Hide the seconds, so the output only changes once per minute
+
+	IconAfter bool   // Place the icon after the time instead of before
+	Separator string // Text between the icon and the time
+	TextOnly  bool   // Show the label of the state instead of the icon
+
+	Theme Theme                       // Icons, colours and labels of the output, under the settings overriding them
+	Ramps map[PomodoroStatus][]string // Icons by status, selected by progress instead of the fixed ones
+
+	PausedDisplay string // How the paused state renders, see PausedFrozen
+
+	MarkupMode   string            // Markup language of the output, see Markup
+	MarkupColors map[string]string // Colour by class (work, rest or paused)
+
+	IdlePaused     bool // Whether the timer was paused because the user went idle
+	CalendarPaused bool // Whether the timer was paused because a calendar event started
+	Fullscreen     bool // Whether a window is fullscreen, deferring the notifications
+	Unblocked      bool // Whether the blocker is lifted until the end of the work period
+
+	Reminders   []*Reminder // Recurring reminders running alongside the pomodoros
+	QuietHours  QuietHours  // Time of day without reminders
+	remindersAt time.Time   // Previous update of the reminders while running, zero otherwise
+
+	UndoStack []Checkpoint // States before the last commands, most recent last
+
+	Profile     string  // Name of the active profile
+	ProfileDir  string  // Directory of the profile files
+	BaseProfile Profile // Settings of the default profile
+
+	Schedule  ProfileSchedule // Profiles switched to at times of day
+	Scheduled time.Time       // Start of the current schedule slot
 }
 
 // NewPomodoro initializes a new PomodoroState instance with given status and pause state
 func NewPomodoro(status PomodoroStatus, paused bool) *PomodoroState {
 	duration := GetDuration(status)
+	now := time.Now()
 	state := &PomodoroState{
-		Status: status,
-		Timer:  time.NewTimer(duration),
-		Ticker: time.NewTicker(time.Second),
-		End:    time.Now().Add(duration),
-		Paused: paused,
+		Status:   status,
+		Timer:    time.NewTimer(duration),
+		Ticker:   time.NewTicker(time.Second),
+		End:      now.Add(duration),
+		Frozen:   now,
+		Paused:   paused,
+		Started:  now,
+		PausedAt: now,
+
+		Separator: " ",
+		AutoStart: [2]bool{true, true},
 	}
 
 	if paused {
@@ -58,51 +177,385 @@ func NewPomodoro(status PomodoroStatus, paused bool) *PomodoroState {
 
 // String returns a formatted string representing the pomodoro timer status
 func (state *PomodoroState) String() string {
-	var suffix string
-	if state.Paused {
-		suffix = PauseEmoji
+	return state.Markup(state.Text())
+}
+
+// Icon returns the icon of the current state, or its label in text-only mode
+func (state *PomodoroState) Icon() string {
+	if state.Paused && state.PausedDisplay == PausedBlink && time.Now().Unix()%2 == 1 {
+		// Blank of the same width, so the time doesn't move
+		if state.TextOnly {
+			return strings.Repeat(" ", utf8.RuneCountInString(Label("paused")))
+		}
+		return strings.Repeat(" ", utf8.RuneCountInString(state.themeIcon("paused", PauseEmoji)))
+	} else if state.TextOnly {
+		return Label(state.Class())
+	} else if state.Paused {
+		return state.themeIcon("paused", PauseEmoji)
+	} else if ramp := state.Ramps[state.Status]; len(ramp) > 0 {
+		// From the first glyph when the period starts to the last one when it ends
+		return ramp[(100-state.Percentage())*len(ramp)/101]
 	} else if state.Status == Work {
-		suffix = TomatoEmoji
-	} else {
-		suffix = RestEmoji
+		return state.themeIcon("work", TomatoEmoji)
+	}
+	return state.themeIcon("rest", RestEmoji)
+}
+
+// themeIcon returns the icon of the class in the theme, or else the fallback
+func (state *PomodoroState) themeIcon(class, fallback string) string {
+	if icon, ok := state.Theme.Icons[class]; ok {
+		return icon
 	}
+	return fallback
+}
 
-	elapsedTime := state.End.Sub(time.Now()).Round(time.Second)
-	minutes := int(elapsedTime.Minutes())
-	seconds := int(elapsedTime.Seconds()) - 60*minutes
+// Text returns the pomodoro timer status, without markup
+func (state *PomodoroState) Text() string {
+	if state.Paused && state.PausedDisplay == PausedHidden {
+		return "" // An empty line hides the polybar module
+	}
 
-	return fmt.Sprintf("%s %02d:%02d", suffix, minutes, seconds)
+	suffix := state.Icon()
+	output := suffix + state.Separator + state.Clock()
+	if state.IconAfter {
+		output = state.Clock() + state.Separator + suffix
+	}
+	if state.ShowToday && state.Goal > 0 {
+		output += fmt.Sprintf(" \u00B7 %d/%d\u2713", state.CompletedToday(), state.Goal)
+	} else if state.ShowToday {
+		output += fmt.Sprintf(" \u00B7 %d\u2713", state.CompletedToday())
+	}
+	for _, reminder := range state.Reminders {
+		if reminder.Due {
+			output += " " + reminder.Name
+		}
+	}
+	if Degraded.Load() {
+		output += " " + WarnEmoji
+	}
+	// Padding counts runes, so the width stays constant across states and icons
+	return fmt.Sprintf("%-*s", state.Width, output)
+}
+
+// CompletedToday returns the number of work periods completed today
+func (state *PomodoroState) CompletedToday() int {
+	if state.TodayDate != time.Now().Format(time.DateOnly) {
+		return 0
+	}
+	return state.Today
+}
+
+// Remaining returns the time left in the current period. While paused, End
+// stays put and the remaining time is measured from Frozen, so nothing needs
+// updating until the timer resumes.
+func (state *PomodoroState) Remaining() time.Duration {
+	if state.Paused {
+		return state.End.Sub(state.Frozen)
+	}
+	return time.Until(state.End)
+}
+
+// TickInterval returns the delay before the output next changes by itself,
+// or 0 when it doesn't (e.g. paused), so the ticker doesn't wake up for nothing
+func (state *PomodoroState) TickInterval() time.Duration {
+	var interval time.Duration
+	if !state.Paused && state.MinutesOnly {
+		// Until the displayed minute changes
+		remaining := state.Remaining()
+		interval = remaining % time.Minute
+		if remaining <= 0 {
+			interval = time.Minute + remaining%time.Minute
+		}
+		interval += 10 * time.Millisecond
+	} else if !state.Paused || state.PausedDisplay == PausedBlink || state.PausedDisplay == PausedCounter {
+		interval = time.Second
+	}
+	if len(state.Schedule) > 0 {
+		if until := state.Schedule.Until(time.Now()); interval == 0 || until < interval {
+			interval = until
+		}
+	}
+	return interval
+}
+
+// Reschedule sets the ticker to the next time the output changes, see TickInterval
+func (state *PomodoroState) Reschedule() {
+	if interval := state.TickInterval(); interval > 0 {
+		state.Ticker.Reset(interval)
+	} else {
+		state.Ticker.Stop()
+	}
+}
+
+// setEnd sets the end of the current period from its remaining time
+func (state *PomodoroState) setEnd(remaining time.Duration) {
+	now := time.Now()
+	state.End, state.Frozen = now.Add(remaining), now
 }
 
 // Pause toggles the paused state of the pomodoro timer
 func (state *PomodoroState) Pause() {
 	if state.Paused {
-		state.Timer.Reset(state.End.Sub(time.Now()))
+		state.End = state.End.Add(time.Since(state.Frozen))
+		state.Timer.Reset(time.Until(state.End))
+		state.PausedFor += time.Since(state.PausedAt)
 	} else {
 		state.Timer.Stop()
+		state.PausedAt, state.Frozen = time.Now(), time.Now()
 	}
 	state.Paused = !state.Paused
 }
 
 // Toggle toggles the pomodoro timer between work and rest status
 func (state *PomodoroState) Toggle() {
+	state.Record(Completed)
+
 	nextStatus := (state.Status + 1) % 2
 	duration := GetDuration(nextStatus)
 
 	state.Status = nextStatus
 	state.Timer.Reset(duration)
-	state.End = time.Now().Add(duration)
+	state.setEnd(duration)
+	state.resetPeriod()
+}
+
+// resetPeriod clears what was tracked about the current period, when a new one starts
+func (state *PomodoroState) resetPeriod() {
+	state.Started, state.PausedAt, state.PausedFor = time.Now(), time.Now(), 0
+	state.Ended = false
+	state.Extended = 0
+	state.Interruptions = nil
+	state.Notes = nil
+	state.Unblocked = false
+}
+
+// Finish handles the timer reaching zero: the next period starts, unless in
+// overtime mode where the current one keeps going until toggled. It returns
+// whether the user should be notified.
+func (state *PomodoroState) Finish() bool {
+	if state.Paused || state.Ended {
+		return false
+	}
+	if state.Overtime {
+		state.Ended = true
+	} else {
+		state.Toggle()
+		if !state.AutoStart[state.Status] {
+			state.Pause()
+		}
+	}
+	return true
+}
+
+// Snooze defers the end of a period that ended in overtime mode by the given duration
+func (state *PomodoroState) Snooze(delay time.Duration) error {
+	if !state.Ended {
+		return errors.New("nothing to snooze: the period hasn't ended")
+	}
+	if delay <= 0 {
+		return fmt.Errorf("invalid snooze delay %s (must be positive)", delay)
+	}
+	state.SetRemaining(delay)
+	return nil
+}
+
+// Restart starts the current period over at its full duration, keeping it paused if it was
+func (state *PomodoroState) Restart() {
+	state.resetPeriod()
+	state.SetRemaining(GetDuration(state.Status))
+}
+
+// Abandon gives up the current work period, recording it as abandoned, and
+// sets up a fresh one, paused
+func (state *PomodoroState) Abandon() error {
+	if state.Status != Work {
+		return errors.New("nothing to abandon: not in a work period")
+	}
+	state.Record(Abandoned)
+	if !state.Paused {
+		state.Pause()
+	}
+	state.Restart()
+	return nil
+}
+
+// Interrupt counts an interruption of the current work period, with its reason
+func (state *PomodoroState) Interrupt(reason string) error {
+	if state.Status != Work {
+		return errors.New("nothing to interrupt: not in a work period")
+	}
+	state.Interruptions = append(state.Interruptions, reason)
+	return nil
+}
+
+// Note attaches a note to the current period, or to the previous one when it
+// was recorded in the history less than NoteGrace ago
+func (state *PomodoroState) Note(text string) error {
+	if text == "" {
+		return errors.New("empty note")
+	}
+	if state.History != nil && time.Since(state.LastRecorded) < NoteGrace {
+		return state.persist(func() error {
+			return state.History.AmendLast(func(session *Session) {
+				session.Notes = append(session.Notes, text)
+			})
+		})
+	}
+	state.Notes = append(state.Notes, text)
+	return nil
+}
+
+// SetTask sets the label of the task being worked on, or clears it when empty
+func (state *PomodoroState) SetTask(label string) {
+	state.Task = label
+}
+
+// Estimate sets the estimated number of pomodoros of the current task
+func (state *PomodoroState) Estimate(pomodoros int) error {
+	if state.Task == "" {
+		return errors.New("no current task: set one with task <label>")
+	}
+	if pomodoros < 1 {
+		return fmt.Errorf("invalid estimate %d", pomodoros)
+	}
+	if state.Estimates == nil {
+		state.Estimates = make(map[string]int)
+	}
+	state.Estimates[state.Task] = pomodoros
+	return nil
+}
+
+// Elapsed returns the time spent running in the current period, excluding pauses
+func (state *PomodoroState) Elapsed() time.Duration {
+	paused := state.PausedFor
+	if state.Paused {
+		paused += time.Since(state.PausedAt)
+	}
+	return max(0, time.Since(state.Started)-paused)
+}
+
+// Record appends the current period to the history, if enabled, with the
+// given result. Periods that never ran are not recorded.
+func (state *PomodoroState) Record(result string) {
+	if state.History == nil || state.Profile == OffProfile || state.Elapsed() < time.Second {
+		return
+	}
+	session := Session{
+		Start:   state.Started,
+		End:     time.Now(),
+		Status:  state.Status.String(),
+		Elapsed: Seconds(state.Elapsed()),
+		Result:  result,
+
+		Interruptions: state.Interruptions,
+		Notes:         state.Notes,
+	}
+	if state.Status == Work {
+		session.Task, session.Estimate = state.Task, state.Estimates[state.Task]
+	}
+	completed := state.Status == Work && result == Completed
+	err := state.persist(func() error {
+		if err := state.History.Append(session); err != nil {
+			return err
+		}
+		if state.Peers != nil {
+			state.Peers.SendSession(session)
+		}
+		// Only a recorded session can unlock achievements
+		if completed && state.Achievements {
+			if err := state.CheckAchievements(); err != nil {
+				slog.Warn("checking achievements", "path", state.History.Path, "err", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("recording session", "path", state.History.Path, "err", err)
+	}
+	state.LastRecorded = time.Now()
+
+	if completed {
+		state.Today = state.CompletedToday() + 1
+		state.TodayDate = time.Now().Format(time.DateOnly)
+	}
+}
+
+// persist applies a change of the history right away, or once the batch being
+// applied succeeds, so a batch that fails leaves the history (and the peers
+// and achievements following it) untouched
+func (state *PomodoroState) persist(change func() error) error {
+	if state.Deferred == nil {
+		return change()
+	}
+	state.Deferred = append(state.Deferred, func() {
+		if err := change(); err != nil {
+			slog.Error("changing history", "path", state.History.Path, "err", err)
+		}
+	})
+	return nil
 }
 
 // Inc increments the pomodoro timer by the given amount
 func (state *PomodoroState) Inc(increment time.Duration) {
-	remainingTime := state.End.Sub(time.Now()) + increment
+	remainingTime := state.Remaining() + increment
 	state.End = state.End.Add(increment).Round(time.Second)
+	if remainingTime > 0 {
+		state.Ended = false
+	}
 	if !state.Paused {
 		state.Timer.Reset(remainingTime)
 	}
 }
 
+// Adjust adds (or removes, when negative) time to the current period on
+// behalf of the inc and dec commands, within the configured limits: the
+// period is never extended by more than MaxExtension in total, and never
+// shortened below MinRemaining
+func (state *PomodoroState) Adjust(step time.Duration) error {
+	if step > 0 && state.MaxExtension > 0 {
+		step = min(step, state.MaxExtension-state.Extended)
+		if step <= 0 {
+			return fmt.Errorf("the period was already extended by %s", state.MaxExtension)
+		}
+	}
+	if step < 0 {
+		step = max(step, state.MinRemaining-state.Remaining().Round(time.Second))
+		if step >= 0 {
+			return fmt.Errorf("less than %s remaining", state.MinRemaining)
+		}
+	}
+	state.Extended += step
+	state.Inc(step)
+	return nil
+}
+
+// Set replaces the status, pause state and remaining time of the current period
+func (state *PomodoroState) Set(status PomodoroStatus, paused bool, remaining time.Duration) {
+	if paused && !state.Paused {
+		state.PausedAt = time.Now()
+	} else if !paused && state.Paused {
+		state.PausedFor += time.Since(state.PausedAt)
+	}
+
+	state.Status = status
+	state.Paused = paused
+	state.setEnd(remaining)
+	state.Ended = state.Overtime && remaining <= 0
+	state.Timer.Stop()
+	if !paused {
+		state.Timer.Reset(remaining)
+	}
+}
+
+// SetRemaining sets the remaining time of the current period
+func (state *PomodoroState) SetRemaining(remaining time.Duration) {
+	state.setEnd(remaining)
+	state.Ended = false
+	if !state.Paused {
+		state.Timer.Reset(remaining)
+	}
+}
+
 // GetDuration returns the duration for the given pomodoro status
 func GetDuration(status PomodoroStatus) time.Duration {
 	return map[PomodoroStatus]time.Duration{
@@ -111,96 +564,1373 @@ func GetDuration(status PomodoroStatus) time.Duration {
 	}[status]
 }
 
-// HandleRequest handles incoming requests over the Unix socket connection
-func HandleRequest(conn *net.UnixConn, pauseChannel, toggleChannel chan struct{}, incChannel chan time.Duration) {
-	buffer := make([]byte, 128)
+// MaxDuration bounds the durations accepted by ParseDuration, either way
+const MaxDuration = 7 * 24 * time.Hour
 
-	n, err := conn.Read(buffer)
+// ParseDuration parses a Go duration string ("25m", "1m30s", "90s"), or a bare
+// number interpreted as (possibly fractional) minutes
+func ParseDuration(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if minutes, ferr := strconv.ParseFloat(value, 64); ferr == nil {
+		if math.IsNaN(minutes) || math.Abs(minutes) > MaxDuration.Minutes() {
+			return 0, fmt.Errorf("invalid duration %q (at most %s)", value, MaxDuration)
+		}
+		duration, err = time.Duration(minutes*float64(time.Minute)).Round(time.Millisecond), nil
+	}
 	if err != nil {
-		fmt.Println("Error reading:", err.Error())
-		return
+		return 0, fmt.Errorf("invalid duration %q (e.g. 25m, 90s or minutes)", value)
+	}
+	if duration > MaxDuration || duration < -MaxDuration {
+		return 0, fmt.Errorf("invalid duration %q (at most %s)", value, MaxDuration)
+	}
+	return duration, nil
+}
+
+// DurationFlag is a flag.Value accepting the durations understood by ParseDuration
+type DurationFlag time.Duration
+
+// String returns the duration formatted as a Go duration string
+func (d *DurationFlag) String() string {
+	return time.Duration(*d).String()
+}
+
+// Set parses the flag value
+func (d *DurationFlag) Set(value string) error {
+	duration, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return fmt.Errorf("duration must be positive: %q", value)
+	}
+	*d = DurationFlag(duration)
+	return nil
+}
+
+// Listen opens a listener for an address of the form scheme://address
+func Listen(addr string) (net.Listener, error) {
+	scheme, address, found := strings.Cut(addr, "://")
+	if !found {
+		return nil, fmt.Errorf("invalid listen address %q (expected tcp://host:port)", addr)
+	}
+
+	switch scheme {
+	case "tcp", "tcp4", "tcp6":
+		return net.Listen(scheme, address)
+	default:
+		return nil, fmt.Errorf("unsupported listen scheme %q", scheme)
+	}
+}
+
+// Authenticate checks that the message starts with the shared token and returns the command that follows it
+func Authenticate(message, token string) (string, bool) {
+	if token == "" {
+		return message, true
+	}
+	secret, command, _ := strings.Cut(message, " ")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(token)) != 1 {
+		return "", false
+	}
+	return command, true
+}
+
+// Command is a client request forwarded to the main loop
+type Command struct {
+	Name  string
+	Args  []string
+	Reply chan string // Used by long-lived requests (join, subscribe) to stream messages back
+
+	Batch []Command // Commands of a batch, applied together with one reply line each
+}
+
+// CommandNames lists the commands clients can send to the daemon
+var CommandNames = []string{"pause", "toggle", "restart", "abandon", "interrupt", "note", "task", "estimate", "inc", "dec", "snooze", "view", "idle", "active", "fullscreen", "undo", "profile", "busy", "free", "reload", "theme", "unblock", "skip", "dismiss"}
+
+// ParseCommand splits a raw client message into a Command
+func ParseCommand(message string) Command {
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return Command{}
+	}
+	return Command{Name: strings.ToLower(fields[0]), Args: fields[1:]}
+}
+
+// ParseBatch splits a raw client message into its commands, separated by
+// semicolons or newlines. Several commands are wrapped into a single batch
+// command, so the main loop applies them at once.
+func ParseBatch(message string) Command {
+	var batch []Command
+	for _, line := range strings.FieldsFunc(message, func(r rune) bool { return r == ';' || r == '\n' }) {
+		if cmd := ParseCommand(line); cmd.Name != "" {
+			batch = append(batch, cmd)
+		}
+	}
+	switch len(batch) {
+	case 0:
+		return Command{}
+	case 1:
+		return batch[0]
+	}
+	return Command{Name: "batch", Batch: batch}
+}
+
+// Unbatchable lists the commands a batch can't revert, refused in batches of several commands
+var Unbatchable = map[string]bool{"reload": true, "theme": true, "sync": true, "merge": true}
+
+// String returns the command formatted as a client message
+func (cmd Command) String() string {
+	if cmd.Name == "batch" {
+		lines := make([]string, len(cmd.Batch))
+		for i, command := range cmd.Batch {
+			lines[i] = command.String()
+		}
+		return strings.Join(lines, "; ")
+	}
+	return strings.Join(append([]string{cmd.Name}, cmd.Args...), " ")
+}
+
+// CheckBatch refuses the batches of several commands holding a command that
+// can't be reverted, returning "ok" for every command otherwise
+func (state *PomodoroState) CheckBatch(batch []Command) ([]string, error) {
+	results := make([]string, len(batch))
+	for i := range results {
+		results[i] = "ok"
+	}
+	if len(batch) < 2 {
+		return results, nil
+	}
+	for i, cmd := range batch {
+		if Unbatchable[cmd.Name] {
+			err := fmt.Errorf("%s can't be part of a batch", cmd.Name)
+			for j := range results {
+				results[j] = "error: skipped"
+			}
+			results[i] = "error: " + err.Error()
+			return results, err
+		}
 	}
-	message := strings.TrimSpace(strings.ToLower(string(buffer[:n])))
+	return results, nil
+}
 
-	switch message {
+// ApplyBatch applies the commands of a batch with dispatch, atomically: once a
+// command fails, the next ones are skipped and the timer is restored as it was
+// before the batch. The changes of the history wait for every command to
+// succeed, so a failed batch records nothing. It returns the result of every
+// command and the index of the one that failed, -1 if none.
+func (state *PomodoroState) ApplyBatch(command Command, dispatch func(Command) error) ([]string, int) {
+	results, err := state.CheckBatch(command.Batch)
+	if err != nil {
+		return results, 0
+	}
+	if len(command.Batch) > 1 {
+		state.Deferred = []func(){}
+	}
+
+	checkpoint, undoStack := state.Checkpoint(command), append([]Checkpoint(nil), state.UndoStack...)
+	failed := -1
+	for i, cmd := range command.Batch {
+		if failed >= 0 {
+			results[i] = "error: skipped"
+		} else if err := dispatch(cmd); err != nil {
+			results[i], failed = "error: "+err.Error(), i
+		}
+	}
+	if failed > 0 {
+		for i := range results[:failed] {
+			results[i] = "error: reverted"
+		}
+		// The changes of the history are dropped, not reverted
+		if err := state.Restore(checkpoint); err != nil {
+			slog.Error("reverting batch", "err", err)
+		}
+		state.UndoStack = undoStack
+	}
+
+	deferred := state.Deferred
+	state.Deferred = nil
+	if failed <= 0 {
+		for _, change := range deferred {
+			change()
+		}
+	}
+	return results, failed
+}
+
+// Apply executes a client command on the pomodoro state
+func (state *PomodoroState) Apply(cmd Command) (err error) {
+	if Undoable[cmd.Name] {
+		checkpoint := state.Checkpoint(cmd)
+		defer func() {
+			if err == nil {
+				state.pushUndo(checkpoint)
+			}
+		}()
+	}
+
+	switch cmd.Name {
 	case "pause":
-		pauseChannel <- struct{}{}
+		state.Pause()
+		state.IdlePaused, state.CalendarPaused = false, false
+	case "idle":
+		state.Idle()
+	case "active":
+		state.Active()
+	case "busy":
+		state.Busy()
+	case "free":
+		state.Free()
+	case "dismiss":
+		return state.Dismiss(strings.Join(cmd.Args, " "))
+	case "unblock":
+		if state.Status != Work {
+			return errors.New("the blocker is only enabled during work periods")
+		}
+		state.Unblocked = true
+	case "fullscreen":
+		if len(cmd.Args) != 1 || (cmd.Args[0] != "on" && cmd.Args[0] != "off") {
+			return errors.New("usage: fullscreen on|off")
+		}
+		state.Fullscreen = cmd.Args[0] == "on"
 	case "toggle":
-		toggleChannel <- struct{}{}
-	case "inc":
-		incChannel <- +5 * time.Second
-	case "dec":
-		incChannel <- -5 * time.Second
+		force := len(cmd.Args) > 0 && cmd.Args[0] == "force"
+		if !force && state.Status == Work && state.Elapsed() < state.MinWork {
+			return fmt.Errorf("toggling is disabled during the first %s of work, send \"toggle force\" to override", state.MinWork)
+		}
+		state.Toggle()
+	case "skip":
+		if state.Status != Rest {
+			return errors.New("only breaks can be skipped")
+		}
+		state.Toggle()
+	case "restart":
+		state.Restart()
+	case "abandon":
+		return state.Abandon()
+	case "interrupt":
+		return state.Interrupt(strings.Join(cmd.Args, " "))
+	case "note":
+		return state.Note(strings.Join(cmd.Args, " "))
+	case "task":
+		state.SetTask(strings.Join(cmd.Args, " "))
+	case "estimate":
+		if len(cmd.Args) != 1 {
+			return errors.New("usage: estimate <pomodoros>")
+		}
+		pomodoros, err := strconv.Atoi(cmd.Args[0])
+		if err != nil {
+			return fmt.Errorf("invalid estimate %q", cmd.Args[0])
+		}
+		return state.Estimate(pomodoros)
+	case "inc", "dec":
+		step := DefaultStep
+		if len(cmd.Args) > 0 {
+			var err error
+			if step, err = ParseDuration(cmd.Args[0]); err != nil {
+				return err
+			}
+		}
+		if cmd.Name == "dec" {
+			step = -step
+		}
+		return state.Adjust(step)
+	case "snooze":
+		delay := DefaultSnooze
+		if len(cmd.Args) > 0 {
+			var err error
+			if delay, err = ParseDuration(cmd.Args[0]); err != nil {
+				return err
+			}
+		}
+		return state.Snooze(delay)
+	case "profile":
+		if len(cmd.Args) != 1 {
+			return errors.New("usage: profile <name>")
+		}
+		return state.SetProfile(cmd.Args[0])
+	case "undo":
+		undone, err := state.Undo()
+		if err != nil {
+			return err
+		}
+		slog.Info("undid command", "command", undone)
+	case "view":
+		return state.SetView(strings.Join(cmd.Args, " "))
+	case "sync":
+		return state.Sync(cmd.Args)
+	case "merge":
+		return state.Merge(cmd.Args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd.Name)
+	}
+	return nil
+}
+
+// Serve accepts connections on the listener and dispatches them to HandleRequest,
+// where access checks the token prefixing every message, if any, and grants
+// the permission of the connection: read-only with the read token, the one
+// of the listener with the token
+func Serve(listener net.Listener, access Access, commands chan Command) {
+	var backoff time.Duration
+	var slots chan struct{} // Held by the open connections, up to MaxConnections
+	if MaxConnections > 0 {
+		slots = make(chan struct{}, MaxConnections)
+	}
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			// Keep accepting after transient errors (e.g. too many open files),
+			// backing off exponentially and flagging the output meanwhile
+			backoff = min(max(2*backoff, AcceptBackoff), MaxAcceptBackoff)
+			slog.Error("accepting connection", "addr", listener.Addr(), "err", err, "retry", backoff)
+			if !Degraded.Swap(true) {
+				RequestRender()
+			}
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+		if Degraded.Swap(false) {
+			RequestRender()
+		}
+		if slots == nil {
+			go HandleRequest(conn, access, commands)
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+			go func() {
+				defer func() { <-slots }()
+				HandleRequest(conn, access, commands)
+			}()
+		default:
+			slog.Warn("rejecting connection, too many open", "addr", listener.Addr(), "max", MaxConnections)
+			conn.Close()
+		}
+	}
+}
+
+// MaxRequest is the maximum length of a request, e.g. a batch or a session merged from a peer
+const MaxRequest = 64 * 1024
+
+// ErrRequestTooLong is returned by ReadRequest for requests longer than MaxRequest
+var ErrRequestTooLong = fmt.Errorf("request too long (more than %d bytes)", MaxRequest)
+
+// ReadRequest reads the first line of a request, along with the next lines
+// sent at once, which make a batch. The last line may lack its newline.
+func ReadRequest(conn io.Reader) (string, error) {
+	reader := bufio.NewReader(io.LimitReader(conn, MaxRequest+1))
+	var request strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		request.WriteString(line)
+		if request.Len() > MaxRequest {
+			return "", ErrRequestTooLong
+		} else if errors.Is(err, io.EOF) && request.Len() > 0 {
+			return request.String(), nil
+		} else if err != nil {
+			return "", err
+		}
+		if reader.Buffered() == 0 {
+			return request.String(), nil
+		}
+	}
+}
+
+// HandleRequest handles incoming requests over a socket connection
+func HandleRequest(conn net.Conn, access Access, commands chan Command) {
+	defer conn.Close()
+	if err := CheckPeer(conn); err != nil {
+		slog.Warn("rejecting request", "err", err)
+		return
+	}
+	request, err := ReadRequest(conn)
+	if errors.Is(err, ErrRequestTooLong) {
+		slog.Warn("rejecting request", "err", err, "remote", conn.RemoteAddr())
+		conn.Write([]byte("error: " + err.Error() + "\n"))
+		return
+	} else if err != nil {
+		slog.Warn("reading request", "err", err)
+		return
+	}
+	message, permission, ok := access.Authenticate(strings.TrimSpace(request))
+	if !ok {
+		slog.Warn("rejecting unauthenticated request", "remote", conn.RemoteAddr())
+		return
+	}
+
+	cmd := ParseBatch(message)
+	slog.Debug("received request", "command", cmd.String(), "remote", conn.RemoteAddr())
+	if !Limiter.Allow(ClientKey(conn), max(1, len(cmd.Batch))) {
+		slog.Warn("rejecting request, rate limit exceeded", "command", cmd.String(), "remote", conn.RemoteAddr())
+		conn.Write([]byte("error: rate limit exceeded\n"))
+		return
+	}
+	if !permission.Allows(cmd) {
+		slog.Warn("rejecting command on a read-only connection", "command", cmd.String(), "remote", conn.RemoteAddr())
+		conn.Write([]byte("error: read-only connection\n"))
+		return
+	}
+	switch cmd.Name {
+	case "":
+	case "batch":
+		// Reply with the result of every command, one line each
+		cmd.Reply = make(chan string, len(cmd.Batch))
+		commands <- cmd
+		for result := range cmd.Reply {
+			if _, err := conn.Write([]byte(result + "\n")); err != nil {
+				return
+			}
+		}
+	case "join", "subscribe":
+		// Keep the connection open and stream every update to the client
+		cmd.Reply = make(chan string, 8)
+		commands <- cmd
+		for update := range cmd.Reply {
+			if _, err := conn.Write([]byte(update + "\n")); err != nil {
+				return
+			}
+		}
+	default:
+		// Applied like a batch of one command, but only a failure is
+		// answered, so scripts piping commands to the socket stay quiet
+		cmd = Command{Name: "batch", Batch: []Command{cmd}, Reply: make(chan string, 1)}
+		commands <- cmd
+		if result := <-cmd.Reply; result != "ok" {
+			conn.Write([]byte(result + "\n"))
+		}
+	}
+}
+
+// Notify shows a desktop notification with the given title and message
+func Notify(title, message string) {
+	cmd := exec.Command("notify-send", "-t", "5000", title, message)
+	if err := cmd.Run(); err != nil {
+		slog.Warn("sending notification", "err", err)
+	}
+}
+
+// ReadCommands forwards the commands read line by line from r (e.g. stdin) to the main loop
+func ReadCommands(r io.Reader, commands chan Command) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		cmd := ParseBatch(scanner.Text())
+		switch cmd.Name {
+		case "":
+		case "join", "subscribe":
+			slog.Warn("command not supported on stdin", "command", cmd.Name)
+		default:
+			commands <- cmd
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("reading stdin", "err", err)
 	}
 }
 
 func main() {
 	// Parse CMD arguments
-	wFlag := flag.Int("w", 25, "Work Period Duration")
-	rFlag := flag.Int("r", 5, "Rest Period Duration")
-	flag.Parse()
+	wFlag := DurationFlag(25 * time.Minute)
+	rFlag := DurationFlag(5 * time.Minute)
+	flag.Var(&wFlag, "w", "Work Period Duration (e.g. 25m, 1h30m or minutes)")
+	flag.Var(&rFlag, "r", "Rest Period Duration (e.g. 5m, 90s or minutes)")
+	listenFlag := flag.String("listen", "", "Additional listener address (e.g. tcp://127.0.0.1:7777)")
+	tokenFlag := flag.String("token", "", "Shared token required to prefix commands on network listeners")
+	readTokenFlag := flag.String("read-token", "", "Shared token granting read-only access (join, subscribe) on network listeners")
+	listenPermissionFlag := flag.String("listen-permission", "control", "Permission of the clients of the network listener: control or read")
+	peerFlag := flag.String("peer", "", "Comma-separated peer daemons to keep in sync (e.g. tcp://laptop:7777)")
+	joinFlag := flag.String("join", "", "Follow the timer of a leader daemon (e.g. tcp://leader:7777)")
+	controlFlag := flag.Bool("control", false, "Forward local commands to the leader when following one")
+	formatFlag := flag.String("format", "polybar", "Standard output format: polybar, waybar, json, conky, genmon, i3rs, eww or none")
+	fifoFlag := flag.String("output-fifo", "", "Write updates into this named pipe instead of stdout")
+	stdinFlag := flag.Bool("stdin", false, "Also accept commands on stdin, one per line")
+	statusFlag := flag.Bool("status-file", false, "Mirror every update to status files under $XDG_RUNTIME_DIR/polybar-pomo")
+	logLevelFlag := flag.String("log-level", "info", "Log level: debug, info, warn or error")
+	logFileFlag := flag.String("log-file", "", "Also append logs to this file")
+	logMaxSizeFlag := flag.Int64("log-max-size", 1024, "Rotate the log file once it grows past this size in KiB (0 for unlimited)")
+	startFlag := flag.Bool("start", false, "Start the first period right away instead of paused")
+	initialFlag := flag.String("initial", "work", "Status of the first period: work or rest")
+	var remainingFlag DurationFlag
+	flag.Var(&remainingFlag, "remaining", "Remaining time of the first period (e.g. 12m), instead of its full duration")
+	historyFlag := flag.Bool("history", false, "Record finished periods in $XDG_DATA_HOME/polybar-pomo/history.jsonl")
+	historyMaxAgeFlag := flag.Int("history-max-age", 0, "Trim the periods older than this many days from the history (0 keeps them all)")
+	goalFlag := flag.Int("goal", 0, "Pomodoros to complete in a day, shown with -today (e.g. 8)")
+	todayFlag := flag.Bool("today", false, "Show the number of pomodoros completed today (requires -history)")
+	achievementsFlag := flag.Bool("achievements", false, "Notify achievements unlocked by completed pomodoros (requires -history)")
+	iconFlag := flag.String("icon-position", "before", "Position of the icon relative to the time: before or after")
+	markupFlag := flag.String("markup", MarkupNone, "Colour the output by state with markup: none, polybar, pango or conky")
+	markupColorsFlag := flag.String("markup-colors", "", "Colours of the states in markup output, over the ones of the theme (e.g. work=#ff5555,rest=#50fa7b,paused=#f1fa8c,ending=#ff0000)")
+	colorsFromFlag := flag.String("colors-from", "", "Read the colours of the states from a generated scheme at startup and reload: pywal or xresources")
+	themeFlag := flag.String("theme", DefaultTheme, "Theme of icons, colours and labels: a preset ("+strings.Join(ThemeNames(), ", ")+") or a file of the themes directory next to the config file")
+	workRampFlag := flag.String("work-ramp", "", "Comma-separated icons of work periods, from full to empty, selected by progress")
+	restRampFlag := flag.String("rest-ramp", "", "Comma-separated icons of rest periods, from full to empty, selected by progress")
+	pausedFlag := flag.String("paused-display", PausedFrozen, "How the paused state renders: frozen, blink, counter or hidden")
+	textFlag := flag.Bool("text", false, "Show the label of the state instead of its icon")
+	labelsFlag := flag.String("labels", "", "Labels of the states (e.g. work=FOCUS,rest=CHILL,paused=HOLD)")
+	separatorFlag := flag.String("separator", " ", "Text between the icon and the time")
+	minutesFlag := flag.Bool("minutes", false, "Hide the seconds and only print a new line when the output changes, about once per minute")
+	widthFlag := flag.Int("width", 0, "Pad the output with spaces to this many characters, so the module doesn't jitter")
+	viewsFlag := flag.String("views", DefaultViews, "Comma-separated display views cycled through by the view command: countdown, countup, end or today")
+	autoWorkFlag := flag.Bool("auto-start-work", true, "Start work periods right away when a rest period ends, instead of paused")
+	autoRestFlag := flag.Bool("auto-start-rest", true, "Start rest periods right away when a work period ends, instead of paused")
+	minWorkFlag := DurationFlag(0)
+	flag.Var(&minWorkFlag, "min-work", "Ignore toggle during the first minutes of a work period (e.g. 2m), unless sent as \"toggle force\"")
+	maxExtensionFlag := DurationFlag(0)
+	flag.Var(&maxExtensionFlag, "max-extension", "Maximum time inc can add to a period in total (e.g. 10m), unlimited by default")
+	minRemainingFlag := DurationFlag(0)
+	flag.Var(&minRemainingFlag, "min-remaining", "Minimum remaining time dec can leave (e.g. 1m)")
+	overtimeFlag := flag.Bool("overtime", false, "Keep counting past the end of a period until toggled, instead of switching automatically")
+	resumeFlag := flag.String("resume", ResumeRestore, "After an unclean shutdown: restore the interrupted period, abort it or off")
+	togglTokenFlag := flag.String("toggl-token", "", "Toggl Track API token, to track work periods as time entries")
+	togglWorkspaceFlag := flag.Int64("toggl-workspace", 0, "Toggl Track workspace id of the time entries")
+	togglProjectsFlag := flag.String("toggl-projects", "", "Toggl Track project ids by task label (e.g. writing=123,*=456)")
+	timewFlag := flag.Bool("timewarrior", false, "Track work periods in Timewarrior, tagged with the task")
+	timewTagsFlag := flag.String("timewarrior-tags", "", "Comma-separated extra Timewarrior tags")
+	awFlag := flag.String("activitywatch", "", "Report work and rest intervals to this ActivityWatch server (e.g. http://localhost:5600)")
+	openRGBFlag := flag.String("openrgb", "", "Set the LED colours of the devices of this OpenRGB server by state (e.g. localhost:6742)")
+	openRGBColorsFlag := flag.String("openrgb-colors", DefaultLightColors, "OpenRGB colours by state, as hex RGB")
+	lightFlag := flag.String("light", "", "Smart light to colour by state: wled://<host> or hue://<bridge>/<username>/<light id>")
+	lightColorsFlag := flag.String("light-colors", DefaultLightColors, "Smart light colours by state, as hex RGB")
+	webhookFlag := flag.String("webhook", "", "Comma-separated URLs to POST a JSON payload to on transitions")
+	webhookEventsFlag := flag.String("webhook-events", DefaultWebhookEvents, "Comma-separated events posted to the webhooks")
+	webhookBodyFlag := flag.String("webhook-body", "", "Go template of the webhook body, instead of the event as JSON")
+	webhookTimeoutFlag := DurationFlag(5 * time.Second)
+	flag.Var(&webhookTimeoutFlag, "webhook-timeout", "Timeout of webhook requests")
+	webhookRetriesFlag := flag.Int("webhook-retries", 3, "Number of retries of failed webhook requests")
+	ntfyFlag := flag.String("ntfy", "", "ntfy topic URL to publish notifications to when the timer ends (e.g. https://ntfy.sh/mytopic)")
+	ntfyTokenFlag := flag.String("ntfy-token", "", "Access token of the ntfy topic")
+	gotifyFlag := flag.String("gotify", "", "Gotify server URL to push notifications to when the timer ends")
+	gotifyTokenFlag := flag.String("gotify-token", "", "Token of the Gotify application")
+	pushoverTokenFlag := flag.String("pushover-token", "", "Pushover application token, to push notifications when the timer ends")
+	pushoverUserFlag := flag.String("pushover-user", "", "Pushover user key of the recipients")
+	telegramTokenFlag := flag.String("telegram-token", "", "Telegram bot token, to push state changes to a chat and accept its commands")
+	telegramChatFlag := flag.Int64("telegram-chat", 0, "Telegram chat id the bot sends messages to")
+	telegramUsersFlag := flag.String("telegram-users", "", "Comma-separated Telegram user ids allowed to send commands (defaults to the chat id)")
+	matrixFlag := flag.String("matrix-homeserver", "", "Matrix homeserver to send state changes to a room (e.g. https://matrix.org)")
+	matrixRoomFlag := flag.String("matrix-room", "", "Matrix room id the state changes are sent to")
+	matrixTokenFlag := flag.String("matrix-token", "", "Access token of the Matrix bot user")
+	discordFlag := flag.String("discord", "", "Discord application id, to show the state as Rich Presence")
+	emailToFlag := flag.String("email-to", "", "Comma-separated addresses to email a daily summary to (requires -history)")
+	emailAtFlag := flag.String("email-at", "18:00", "Time of day the daily summary is sent at")
+	emailFromFlag := flag.String("email-from", "", "Sender address of the daily summary")
+	smtpFlag := flag.String("smtp-server", "localhost:25", "SMTP server sending the daily summary, as host:port")
+	smtpUserFlag := flag.String("smtp-user", "", "SMTP user, when the server requires authentication")
+	smtpPasswordFlag := flag.String("smtp-password", "", "SMTP password")
+	idleFlag := DurationFlag(0)
+	flag.Var(&idleFlag, "idle", "Pause the timer after being idle this long on Wayland (e.g. 5m), resuming on activity (requires swayidle)")
+	hyprlandWorkFlag := flag.String("hyprland-work", "", "Hyprland commands to run when working, separated by ';' (e.g. keyword general:col.active_border rgb(ff0000))")
+	hyprlandRestFlag := flag.String("hyprland-rest", "", "Hyprland commands to run when resting")
+	hyprlandPausedFlag := flag.String("hyprland-paused", "", "Hyprland commands to run when paused")
+	hyprlandExitFlag := flag.String("hyprland-exit", "", "Hyprland commands to run on exit, e.g. to restore the border colour")
+	hyprlandHintsFlag := flag.Bool("hyprland-hints", false, "Defer notifications while a Hyprland window is fullscreen")
+	focusCloseFlag := flag.String("focus-close", "", "Comma-separated classes of the applications closed while working, started again on break (e.g. discord,thunderbird)")
+	focusMinimizeFlag := flag.String("focus-minimize", "", "Comma-separated classes of the applications minimized while working, restored on break")
+	focusStartFlag := flag.String("focus-start", "", "Commands of the applications started while working, separated by ';', stopped on break")
+	focusWMFlag := flag.String("focus-wm", "auto", "Window manager of -focus-close and -focus-minimize: auto, sway or wmctrl")
+	breakWorkspaceFlag := flag.String("break-workspace", "", "Workspace switched to when a break starts, switching back when work resumes (a desktop number with wmctrl)")
+	breakWindowFlag := flag.String("break-window", "", "Class of a window shown when a break starts, e.g. a stretching app")
+	breakWMFlag := flag.String("break-wm", "auto", "Window manager of -break-workspace and -break-window: auto, sway, i3, hyprland or wmctrl")
+	eyeBreakFlag := DurationFlag(0)
+	flag.Var(&eyeBreakFlag, "eye-break", "Prompt for a micro-break after this much work (e.g. 20m for the 20-20-20 rule), keeping the timer running")
+	eyeBreakLengthFlag := DurationFlag(20 * time.Second)
+	flag.Var(&eyeBreakLengthFlag, "eye-break-length", "Length of the micro-breaks of -eye-break")
+	eyeBreakOverlayFlag := flag.Bool("eye-break-overlay", false, "Cover the screen during the micro-breaks of -eye-break (requires yad)")
+	breakOverlayFlag := flag.Bool("break-overlay", false, "Cover the screen during breaks with a countdown and a skip button (requires yad)")
+	breakBrightnessFlag := flag.String("break-brightness", "", "Screen brightness during breaks, as a brightnessctl value (e.g. 30%)")
+	breakGammaFlag := flag.Int("break-gamma", 0, "Colour temperature of the screen during breaks in Kelvin (e.g. 3000, requires gammastep)")
+	blockHostsFlag := flag.String("block-hosts", "", "Comma-separated websites blocked in the hosts file while working (e.g. reddit.com,news.ycombinator.com)")
+	blockHostsFileFlag := flag.String("block-hosts-file", "/etc/hosts", "Hosts file of -block-hosts, which must be writable by the user")
+	blockOnFlag := flag.String("block-on", "", "Shell command enabling an external blocker when a work period starts")
+	blockOffFlag := flag.String("block-off", "", "Shell command lifting the external blocker on break")
+	polybarActionsFlag := flag.String("polybar-actions", "", "Polybar actions triggered with polybar-msg by state (e.g. work=#dnd.hook.0,rest=#dnd.hook.1,exit=#dnd.hook.1)")
+	onceFlag := flag.Bool("once", false, "Print the state of the running daemon in the -format once and exit")
+	langFlag := flag.String("lang", "", "Language of the messages and reports (e.g. de), detected from the locale by default")
+	profileFlag := flag.String("profile", DefaultProfile, "Profile applied at startup, read from the profiles directory next to the config file")
+	remindersFlag := flag.String("reminders", "", "Comma-separated reminders shown and notified while the timer runs, as name=interval (e.g. 🧍=45m,💧=1h)")
+	quietHoursFlag := flag.String("quiet-hours", "", "Time of day without reminders (e.g. 22:00-08:00)")
+	scheduleFlag := flag.String("profile-schedule", "", "Comma-separated times of day to switch profiles at (e.g. 09:00=email,10:00=deepwork)")
+	calendarFlag := flag.String("calendar-command", "", "Command printing today's busy intervals, one \"HH:MM HH:MM\" per line (e.g. from khal or calcurse), pausing the timer during them")
+	calendarIntervalFlag := DurationFlag(time.Minute)
+	flag.Var(&calendarIntervalFlag, "calendar-interval", "Interval between runs of the calendar command")
+	gnomePomodoroFlag := flag.Bool("gnome-pomodoro", false, "Serve a subset of the org.gnome.Pomodoro D-Bus interface on the session bus, for gnome-pomodoro extensions and scripts")
+	socketModeFlag := flag.String("socket-mode", "0600", "Octal permissions of the Unix socket file (e.g. 0660 with -socket-group)")
+	socketGroupFlag := flag.String("socket-group", "", "Group owning the Unix socket file, whose members may send commands like -allow-uids")
+	allowUIDsFlag := flag.String("allow-uids", "", "Comma-separated user ids allowed to send commands over the Unix socket, besides the owner")
+	rateLimitFlag := flag.Float64("rate-limit", Limiter.Rate, "Commands accepted per second from each client on average, in bursts of twice as many (0 for unlimited)")
+	maxConnectionsFlag := flag.Int("max-connections", MaxConnections, "Concurrent connections accepted by each listener, including subscribers (0 for unlimited)")
+	httpFlag := flag.String("http", "", "Serve the HTTP API on this address (e.g. 127.0.0.1:7778), with the server-sent events of /events")
+	tlsCertFlag := flag.String("tls-cert", "", "PEM certificate serving -listen and -http over TLS, also presented to tls:// daemons")
+	tlsKeyFlag := flag.String("tls-key", "", "PEM key of -tls-cert")
+	tlsCAFlag := flag.String("tls-ca", "", "PEM CA certificates verifying the peers: clients of -listen and -http must present a certificate it signed, and tls:// daemons too")
+	mdnsFlag := flag.Bool("mdns", false, "Advertise the -listen and -http listeners on the LAN over mDNS (requires avahi-publish)")
+	debugAddrFlag := flag.String("debug-addr", "", "Serve the pprof profiles on this address (e.g. localhost:6060)")
+	daemonFlag := flag.Bool("daemon", false, "Run in the background, with a PID file and logs in $XDG_DATA_HOME/polybar-pomo/daemon.log unless -log-file is set")
+	socketFlag := flag.String("socket", SocketPath, "Unix socket path of the daemon, also used by the subcommands")
+	configFlag := flag.String("config", DefaultConfigPath(), "Config file of 'flag = value' lines, overridden by the command line")
+	flag.Usage = Usage
+	// The daemon runs without a subcommand, or with the daemon one
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "daemon" {
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	pinned, err := LoadEnv(flag.CommandLine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading environment:", err.Error())
+		os.Exit(1)
+	}
+
+	// Flags given on the command line or in the environment override the
+	// config file, also when it is reloaded
+	flag.Visit(func(f *flag.Flag) { pinned[f.Name] = true })
+
+	// Settings missing from the command line are read from the config file,
+	// unless it is the one being checked or written by the config subcommand.
+	// The doctor subcommand reports its errors instead.
+	if flag.Arg(0) != "config" {
+		if err := LoadConfig(flag.CommandLine, *configFlag, pinned); err != nil && flag.Arg(0) != "doctor" {
+			if !errors.Is(err, fs.ErrNotExist) || *configFlag != DefaultConfigPath() {
+				fmt.Fprintln(os.Stderr, "Error loading config:", err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+
+	logFile, err := SetupLogging(*logLevelFlag, *logFileFlag, *logMaxSizeFlag*1024)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error setting up logging:", err.Error())
+		os.Exit(1)
+	}
+	defer logFile.Close()
+
+	if err := SetLanguage(*langFlag); err != nil {
+		slog.Warn("setting language", "err", err)
+	}
+	Labels = ParseMapping(*labelsFlag)
+	SocketPath = *socketFlag
+	TLS = TLSFiles{Cert: *tlsCertFlag, Key: *tlsKeyFlag, CA: *tlsCAFlag}
+
+	// Subcommands talk to a running daemon or read the history instead of
+	// starting the daemon
+	if name := flag.Arg(0); name == "help" {
+		if err := Help(flag.Args()[1:]); err != nil {
+			slog.Error("printing help", "err", err)
+			os.Exit(1)
+		}
+		return
+	} else if subcommand, ok := Subcommands()[name]; ok {
+		if err := subcommand.Run(flag.Args()[1:]); err != nil {
+			slog.Error("running "+name, "err", err)
+			os.Exit(1)
+		}
+		return
+	} else if name != "" {
+		fmt.Fprintf(os.Stderr, "Unknown subcommand %q\n\n", name)
+		Usage()
+		os.Exit(2)
+	}
+
+	render, ok := Renderers[*formatFlag]
+	if !ok && *formatFlag != "none" {
+		slog.Error("unknown output format", "format", *formatFlag)
+		os.Exit(1)
+	}
+
+	if *onceFlag {
+		if err := Once(*formatFlag); err != nil {
+			slog.Error("printing state", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	initial, err := ParseStatus(*initialFlag)
+	if err != nil {
+		slog.Error("parsing -initial", "err", err)
+		os.Exit(1)
+	}
+
+	if *resumeFlag != ResumeRestore && *resumeFlag != ResumeAbort && *resumeFlag != ResumeOff {
+		slog.Error("invalid -resume policy", "policy", *resumeFlag)
+		os.Exit(1)
+	}
 
 	// Set Work and Rest Time Perimeters
-	WorkDuration = time.Duration(*wFlag) * time.Minute
-	RestDuration = time.Duration(*rFlag) * time.Minute
+	WorkDuration = time.Duration(wFlag)
+	RestDuration = time.Duration(rFlag)
 
-	// Remove existing socket file if it exists
+	if *allowUIDsFlag != "" {
+		uids, err := ParseUIDs(*allowUIDsFlag)
+		if err != nil {
+			slog.Error("parsing -allow-uids", "err", err)
+			os.Exit(1)
+		}
+		for uid := range uids {
+			AllowedUIDs[uid] = true
+		}
+	}
+
+	socketMode, err := ParseSocketMode(*socketModeFlag)
+	if err != nil {
+		slog.Error("parsing -socket-mode", "err", err)
+		os.Exit(1)
+	}
+	if *socketGroupFlag != "" {
+		if AllowedGroup, err = LookupGroup(*socketGroupFlag); err != nil {
+			slog.Error("parsing -socket-group", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	if *rateLimitFlag < 0 || *maxConnectionsFlag < 0 {
+		slog.Error("-rate-limit and -max-connections can't be negative")
+		os.Exit(1)
+	}
+	Limiter.Rate, Limiter.Burst = *rateLimitFlag, 2**rateLimitFlag
+	MaxConnections = *maxConnectionsFlag
+
+	listenPermission, err := ParsePermission(*listenPermissionFlag)
+	if err != nil {
+		slog.Error("parsing -listen-permission", "err", err)
+		os.Exit(1)
+	}
+
+	if *daemonFlag && os.Getenv(DaemonEnv) == "" {
+		if err := Daemonize(*logFileFlag); err != nil {
+			slog.Error("starting daemon", "err", err)
+			os.Exit(1)
+		}
+		return
+	} else if os.Getenv(DaemonEnv) != "" {
+		if err := WritePID(); err != nil {
+			slog.Error("writing PID file", "path", PIDPath(), "err", err)
+			os.Exit(1)
+		}
+		defer os.Remove(PIDPath())
+	}
+
+	var fifo *FIFO
+	if *fifoFlag != "" && render != nil {
+		if fifo, err = OpenFIFO(*fifoFlag); err != nil {
+			slog.Error("opening fifo", "path", *fifoFlag, "err", err)
+			return
+		}
+	}
+
+	// Remove existing socket file if it exists. The default directory must be
+	// private, while a -socket elsewhere is up to the user.
+	mkdir := func(dir string) error { return os.MkdirAll(dir, 0o700) }
+	if filepath.Dir(SocketPath) == StatusDir() {
+		mkdir = MakeStatusDir
+	}
+	if err := mkdir(filepath.Dir(SocketPath)); err != nil {
+		slog.Error("creating socket directory", "path", filepath.Dir(SocketPath), "err", err)
+		return
+	}
 	if err := os.RemoveAll(SocketPath); err != nil {
-		fmt.Println("Error removing socket file:", err.Error())
+		slog.Error("removing socket file", "path", SocketPath, "err", err)
 		return
 	}
 
 	// Attempt to listen to the Unix socket
-	listener, err := net.ListenUnix("unix", &net.UnixAddr{SocketPath, "unix"})
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: SocketPath, Net: "unix"})
 	if err != nil {
-		fmt.Println("Error listening:", err.Error())
+		slog.Error("listening", "addr", SocketPath, "err", err)
 		return
 	}
 	defer listener.Close()
 	defer os.Remove(SocketPath)
+	if err := os.Chmod(SocketPath, socketMode); err != nil {
+		slog.Error("setting the socket mode", "path", SocketPath, "err", err)
+		return
+	}
+	if AllowedGroup >= 0 {
+		if err := os.Chown(SocketPath, -1, AllowedGroup); err != nil {
+			slog.Error("setting the socket group", "path", SocketPath, "err", err)
+			return
+		}
+	}
+	if SocketPath == DefaultSocketPath() {
+		if err := LinkLegacySocket(); err != nil {
+			slog.Warn("linking the legacy socket path", "path", LegacySocketPath, "err", err)
+		} else {
+			defer os.Remove(LegacySocketPath)
+		}
+	}
+
+	// Optionally listen on a network address as well (e.g. for remote
+	// clients), over TLS when a certificate is set
+	tlsConfig, err := TLS.ServerConfig()
+	if err != nil {
+		slog.Error("configuring TLS", "err", err)
+		return
+	}
+	var netListener net.Listener
+	if *listenFlag != "" {
+		netListener, err = Listen(*listenFlag)
+		if err != nil {
+			slog.Error("listening", "addr", *listenFlag, "err", err)
+			return
+		}
+		if tlsConfig != nil {
+			netListener = tls.NewListener(netListener, tlsConfig)
+		}
+		slog.Info("listening", "addr", netListener.Addr())
+		defer netListener.Close()
+	}
 
-	// Goroutine function to handle incoming Unix socket connections
-	pauseChannel := make(chan struct{})
-	toggleChannel := make(chan struct{})
-	incChannel := make(chan time.Duration)
+	// Goroutines to handle incoming socket connections
+	commands := make(chan Command)
 
-	go func() {
-		for {
-			conn, err := listener.AcceptUnix()
+	go Serve(listener, Access{Permission: FullControl}, commands)
+	if netListener != nil {
+		go Serve(netListener, Access{Token: *tokenFlag, ReadToken: *readTokenFlag, Permission: listenPermission}, commands)
+	}
+	if *stdinFlag {
+		go ReadCommands(os.Stdin, commands)
+	}
+	if idleFlag > 0 {
+		go func() {
+			if err := WatchIdle(time.Duration(idleFlag), commands); err != nil {
+				slog.Error("watching idleness", "err", err)
+			}
+		}()
+	}
+
+	if *httpFlag != "" {
+		go func() {
+			if err := ServeHTTPAPI(*httpFlag, tlsConfig, Access{Token: *tokenFlag, ReadToken: *readTokenFlag, Permission: listenPermission}, commands); err != nil {
+				slog.Error("serving the HTTP API", "addr", *httpFlag, "err", err)
+			}
+		}()
+	}
+	if *mdnsFlag {
+		advertised := make(map[string]string) // Address by protocol
+		if netListener != nil {
+			advertised["tcp"] = netListener.Addr().String()
+		}
+		if *httpFlag != "" {
+			advertised["http"] = *httpFlag
+		}
+		if len(advertised) == 0 {
+			slog.Warn("-mdns requires -listen or -http")
+		}
+		for protocol, addr := range advertised {
+			protocol, addr := protocol, addr
+			port, err := AdvertisedPort(addr)
 			if err != nil {
-				fmt.Println("Error accepting connection:", err.Error())
-				return
+				slog.Error("advertising over mDNS", "addr", addr, "err", err)
+				continue
 			}
-			go HandleRequest(conn, pauseChannel, toggleChannel, incChannel)
+			go func() {
+				if err := Advertise(protocol, port, *tokenFlag != ""); err != nil {
+					slog.Error("advertising over mDNS", "addr", addr, "err", err)
+				}
+			}()
 		}
-	}()
+	}
+	if *debugAddrFlag != "" {
+		if err := CheckDebugAddr(*debugAddrFlag); err != nil {
+			slog.Error("invalid -debug-addr", "err", err)
+			os.Exit(1)
+		}
+		go func() {
+			if err := ServeDebug(*debugAddrFlag); err != nil {
+				slog.Error("serving profiles", "addr", *debugAddrFlag, "err", err)
+			}
+		}()
+	}
+	if *gnomePomodoroFlag {
+		go func() {
+			if err := ServeGnomePomodoro(commands); err != nil {
+				slog.Error("serving the gnome-pomodoro interface", "err", err)
+			}
+		}()
+	}
+	if *calendarFlag != "" {
+		go WatchCalendar(*calendarFlag, time.Duration(calendarIntervalFlag), commands)
+	}
+
+	// Peer daemons receive a snapshot after every local state change, and the
+	// recorded periods
+	peers := ParsePeers(*peerFlag, *tokenFlag)
+
+	// Renderers and followers (team mode) subscribe to state updates
+	var subscribers Subscribers
+	var statusFiles *StatusFiles
+	if *statusFlag {
+		statusFiles = &StatusFiles{Dir: StatusDir()}
+	}
+	var leader *Leader
+	if *joinFlag != "" {
+		leader = &Leader{Addr: *joinFlag, Token: *tokenFlag, Control: *controlFlag}
+		go leader.Follow(commands)
+	}
+
+	// applySettings sets the reloadable settings of the flags on the state,
+	// checking all of them before changing anything
+	applySettings := func(state *PomodoroState) error {
+		if *markupFlag != MarkupNone && *markupFlag != MarkupPolybar && *markupFlag != MarkupPango && *markupFlag != MarkupConky {
+			return fmt.Errorf("invalid -markup %q", *markupFlag)
+		}
+		if *pausedFlag != PausedFrozen && *pausedFlag != PausedBlink && *pausedFlag != PausedCounter && *pausedFlag != PausedHidden {
+			return fmt.Errorf("invalid -paused-display %q", *pausedFlag)
+		}
+		if *iconFlag != "before" && *iconFlag != "after" {
+			return fmt.Errorf("invalid -icon-position %q", *iconFlag)
+		}
+		views, err := ParseViews(*viewsFlag)
+		if err != nil {
+			return fmt.Errorf("parsing -views: %w", err)
+		}
+		var schedule ProfileSchedule
+		if *scheduleFlag != "" {
+			if schedule, err = ParseProfileSchedule(*scheduleFlag); err != nil {
+				return fmt.Errorf("parsing -profile-schedule: %w", err)
+			}
+		}
+		var reminders []*Reminder
+		if *remindersFlag != "" {
+			if reminders, err = ParseReminders(*remindersFlag); err != nil {
+				return fmt.Errorf("parsing -reminders: %w", err)
+			}
+		}
+		var quietHours QuietHours
+		if *quietHoursFlag != "" {
+			if quietHours, err = ParseQuietHours(*quietHoursFlag); err != nil {
+				return fmt.Errorf("parsing -quiet-hours: %w", err)
+			}
+		}
+		theme, err := LoadTheme(filepath.Join(filepath.Dir(*configFlag), "themes"), *themeFlag)
+		if err != nil {
+			return err
+		}
+
+		state.Overtime = *overtimeFlag
+		state.AutoStart[Work], state.AutoStart[Rest] = *autoWorkFlag, *autoRestFlag
+		state.MinWork = time.Duration(minWorkFlag)
+		state.MaxExtension, state.MinRemaining = time.Duration(maxExtensionFlag), time.Duration(minRemainingFlag)
+		state.Views = views
+		state.Width = *widthFlag
+		state.MinutesOnly = *minutesFlag
+		state.IconAfter, state.Separator = *iconFlag == "after", *separatorFlag
+		state.TextOnly = *textFlag
+		state.PausedDisplay = *pausedFlag
+		state.SetReminders(reminders)
+		state.QuietHours = quietHours
+		state.Schedule, state.Goal = schedule, *goalFlag
+		state.Theme = theme
+		state.Ramps = overlay(theme.Ramps, nil)
+		if *workRampFlag != "" {
+			state.Ramps[Work] = strings.Split(*workRampFlag, ",")
+		}
+		if *restRampFlag != "" {
+			state.Ramps[Rest] = strings.Split(*restRampFlag, ",")
+		}
+		colors := ParseMapping(*markupColorsFlag)
+		if *colorsFromFlag != "" {
+			// A missing scheme (e.g. before pywal first ran) leaves the theme colours
+			if scheme, err := LoadColorScheme(*colorsFromFlag); err != nil {
+				slog.Warn("reading the colour scheme", "source", *colorsFromFlag, "err", err)
+			} else {
+				theme.Colors = overlay(theme.Colors, scheme.Classes())
+				colors = scheme.Resolve(colors)
+			}
+		}
+		state.MarkupMode, state.MarkupColors = *markupFlag, overlay(theme.Colors, colors)
+		Labels = overlay(theme.Labels, ParseMapping(*labelsFlag))
+		return nil
+	}
 
 	// Create a new PomodoroState instance with initial status
-	state := NewPomodoro(Work, true)
+	state := NewPomodoro(initial, !*startFlag)
+	if remainingFlag > 0 {
+		state.SetRemaining(time.Duration(remainingFlag))
+	}
+	if err := applySettings(state); err != nil {
+		slog.Error("applying settings", "err", err)
+		os.Exit(1)
+	}
+	state.Profile, state.BaseProfile = DefaultProfile, state.CurrentProfile(DefaultProfile)
+	state.ProfileDir = filepath.Join(filepath.Dir(*configFlag), "profiles")
+	if err := state.SetProfile(*profileFlag); err != nil {
+		slog.Error("applying profile", "err", err)
+		os.Exit(1)
+	}
+	if len(state.Schedule) > 0 {
+		for _, slot := range state.Schedule {
+			if _, err := LoadProfile(state.ProfileDir, slot.Profile, state.BaseProfile); err != nil {
+				slog.Error("checking the profile schedule", "err", err)
+				os.Exit(1)
+			}
+		}
+		state.FollowSchedule()
+	}
+	if *historyFlag {
+		state.History = &History{Path: HistoryPath(), MaxAge: time.Duration(*historyMaxAgeFlag) * 24 * time.Hour}
+		state.Peers = peers
+	}
+	if *todayFlag {
+		if state.History == nil {
+			slog.Error("-today requires -history")
+			os.Exit(1)
+		}
+		state.ShowToday = true
+		state.TodayDate = time.Now().Format(time.DateOnly)
+		if state.Today, err = state.History.CountCompleted(time.Now()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("counting today's pomodoros", "path", state.History.Path, "err", err)
+		}
+	}
+
+	if *achievementsFlag {
+		if state.History == nil {
+			slog.Error("-achievements requires -history")
+			os.Exit(1)
+		}
+		state.Achievements = true
+	}
+
+	// Integrations with external tools receive the timer events
+	var integrations Integrations
+	if *togglTokenFlag != "" {
+		toggl, err := NewToggl(*togglTokenFlag, *togglWorkspaceFlag, *togglProjectsFlag)
+		if err != nil {
+			slog.Error("configuring toggl", "err", err)
+			os.Exit(1)
+		}
+		integrations.Add(toggl)
+	}
+	if *timewFlag {
+		timew := &Timewarrior{}
+		if *timewTagsFlag != "" {
+			timew.Tags = strings.Split(*timewTagsFlag, ",")
+		}
+		integrations.Add(timew)
+	}
+	if *openRGBFlag != "" {
+		openRGB, err := NewOpenRGB(*openRGBFlag, *openRGBColorsFlag)
+		if err != nil {
+			slog.Error("configuring openrgb", "err", err)
+			os.Exit(1)
+		}
+		integrations.Add(openRGB)
+	}
+	if *lightFlag != "" {
+		light, err := NewLight(*lightFlag, *lightColorsFlag)
+		if err != nil {
+			slog.Error("configuring light", "err", err)
+			os.Exit(1)
+		}
+		integrations.Add(light)
+	}
+	if *webhookFlag != "" {
+		webhook, err := NewWebhook(*webhookFlag, *webhookEventsFlag, *webhookBodyFlag, time.Duration(webhookTimeoutFlag), *webhookRetriesFlag)
+		if err != nil {
+			slog.Error("configuring webhook", "err", err)
+			os.Exit(1)
+		}
+		integrations.Add(webhook)
+	}
+	if *ntfyFlag != "" {
+		integrations.Add(&Push{Service: "ntfy", Pusher: &Ntfy{URL: *ntfyFlag, Token: *ntfyTokenFlag}})
+	}
+	if *gotifyFlag != "" {
+		integrations.Add(&Push{Service: "gotify", Pusher: &Gotify{URL: *gotifyFlag, Token: *gotifyTokenFlag}})
+	}
+	if *pushoverTokenFlag != "" {
+		integrations.Add(&Push{Service: "pushover", Pusher: &Pushover{Token: *pushoverTokenFlag, User: *pushoverUserFlag}})
+	}
+	if *telegramTokenFlag != "" {
+		telegram, err := NewTelegram(*telegramTokenFlag, *telegramChatFlag, *telegramUsersFlag)
+		if err != nil {
+			slog.Error("configuring telegram", "err", err)
+			os.Exit(1)
+		}
+		integrations.Add(telegram)
+		go telegram.Poll(commands)
+	}
+	if *matrixFlag != "" {
+		integrations.Add(NewMatrix(*matrixFlag, *matrixRoomFlag, *matrixTokenFlag))
+	}
+	if *discordFlag != "" {
+		integrations.Add(&Discord{ClientID: *discordFlag})
+	}
+	if *hyprlandWorkFlag != "" || *hyprlandRestFlag != "" || *hyprlandPausedFlag != "" || *hyprlandExitFlag != "" {
+		hyprland, err := NewHyprland(map[string]string{
+			"work":   *hyprlandWorkFlag,
+			"rest":   *hyprlandRestFlag,
+			"paused": *hyprlandPausedFlag,
+			"exit":   *hyprlandExitFlag,
+		})
+		if err != nil {
+			slog.Error("configuring hyprland", "err", err)
+			os.Exit(1)
+		}
+		integrations.Add(hyprland)
+	}
+	if *hyprlandHintsFlag {
+		go func() {
+			if err := WatchHyprland(commands); err != nil {
+				slog.Error("watching hyprland", "err", err)
+			}
+		}()
+	}
+	if *focusCloseFlag != "" || *focusMinimizeFlag != "" || *focusStartFlag != "" {
+		wm, err := NewWindowManager(*focusWMFlag)
+		if err != nil {
+			slog.Error("configuring focus sessions", "err", err)
+			os.Exit(1)
+		}
+		focus := &Focus{WM: wm}
+		if *focusCloseFlag != "" {
+			focus.Close = strings.Split(*focusCloseFlag, ",")
+		}
+		if *focusMinimizeFlag != "" {
+			focus.Minimize = strings.Split(*focusMinimizeFlag, ",")
+		}
+		for _, command := range strings.Split(*focusStartFlag, ";") {
+			if command = strings.TrimSpace(command); command != "" {
+				focus.Start = append(focus.Start, command)
+			}
+		}
+		integrations.Add(focus)
+	}
+	if *breakWorkspaceFlag != "" || *breakWindowFlag != "" {
+		wm, err := NewWorkspaces(*breakWMFlag)
+		if err != nil {
+			slog.Error("configuring the break workspace", "err", err)
+			os.Exit(1)
+		}
+		integrations.Add(&BreakWorkspace{WM: wm, Workspace: *breakWorkspaceFlag, Window: *breakWindowFlag})
+	}
+	if *breakOverlayFlag {
+		integrations.Add(&Overlay{Commands: commands})
+	}
+	if *breakBrightnessFlag != "" || *breakGammaFlag > 0 {
+		integrations.Add(&Dimmer{Brightness: *breakBrightnessFlag, Temperature: *breakGammaFlag})
+	}
+	if *blockHostsFlag != "" || *blockOnFlag != "" || *blockOffFlag != "" {
+		blocker := &Blocker{HostsFile: *blockHostsFileFlag, On: *blockOnFlag, Off: *blockOffFlag}
+		if *blockHostsFlag != "" {
+			blocker.Hosts = strings.Split(*blockHostsFlag, ",")
+		}
+		integrations.Add(blocker)
+	}
+	if *polybarActionsFlag != "" {
+		integrations.Add(&PolybarActions{Actions: ParseMapping(*polybarActionsFlag)})
+	}
+	if *awFlag != "" {
+		integrations.Add(NewActivityWatch(*awFlag))
+	}
+
+	// Email a summary of the day from the history
+	if *emailToFlag != "" {
+		if state.History == nil {
+			slog.Error("-email-to requires -history")
+			os.Exit(1)
+		}
+		if _, err := nextTimeOfDay(*emailAtFlag, time.Now()); err != nil {
+			slog.Error("parsing -email-at", "err", err)
+			os.Exit(1)
+		}
+		email := &DailyEmail{
+			At:       *emailAtFlag,
+			History:  state.History,
+			Server:   *smtpFlag,
+			User:     *smtpUserFlag,
+			Password: *smtpPasswordFlag,
+			From:     *emailFromFlag,
+			To:       strings.Split(*emailToFlag, ","),
+		}
+		go email.Run()
+	}
+
+	// Resume the period interrupted by a crash, if any
+	var journal *Journal
+	if *resumeFlag != ResumeOff {
+		journal = &Journal{Path: filepath.Join(DataDir(), "journal.json")}
+		if err := journal.Resume(state, *resumeFlag); err != nil {
+			slog.Warn("resuming from journal", "path", journal.Path, "err", err)
+		}
+	}
+
+	// Shut down cleanly on SIGINT and SIGTERM (e.g. when polybar exits)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	signal.Ignore(syscall.SIGPIPE) // Handled by LineOutput
+
+	// Outputs refreshed after every change of the state, in order
+	notifier := &Notifier{}
+	outputs := []Output{notifier, &subscribers, &integrations}
+	if journal != nil {
+		outputs = append(outputs, journal)
+	}
+	var heartbeat <-chan time.Time
+	if statusFiles != nil {
+		outputs = append(outputs, statusFiles)
+		heartbeat = time.NewTicker(StatusStale / 2).C
+	}
+	if eyeBreakFlag > 0 {
+		outputs = append(outputs, &EyeBreaks{Every: time.Duration(eyeBreakFlag), Length: time.Duration(eyeBreakLengthFlag), Overlay: *eyeBreakOverlayFlag})
+	}
+	if render != nil {
+		outputs = append(outputs, &LineOutput{Render: render, FIFO: fifo})
+	}
+
+	// reapply applies the settings of the flags again, under the current profile
+	reapply := func() error {
+		if err := applySettings(state); err != nil {
+			return err
+		}
+		state.BaseProfile = state.CurrentProfile(DefaultProfile)
+		state.BaseProfile.Work, state.BaseProfile.Rest = time.Duration(wFlag), time.Duration(rFlag)
+		return state.SetProfile(state.Profile)
+	}
+
+	// reload reads the config file again and applies its reloadable settings,
+	// keeping the running period: its duration changes like with the profile command
+	reload := func() error {
+		if err := ReloadConfig(flag.CommandLine, *configFlag, pinned); err != nil {
+			return err
+		}
+		if err := reapply(); err != nil {
+			return err
+		}
+		// The schedule may have changed, so the current slot applies again
+		state.Scheduled = time.Time{}
+		state.FollowSchedule()
+		return nil
+	}
 
-	var inc time.Duration
-	cmd := exec.Command("notify-send", "-t", "5000", "Pomodoro", "Timer reached zero")
+	// dispatch applies a command, or forwards it to the leader when following one
+	dispatch := func(command Command) error {
+		if command.Name == "reload" {
+			if err := reload(); err != nil {
+				slog.Warn("reloading config", "path", *configFlag, "err", err)
+				return err
+			}
+			slog.Info("reloaded config", "path", *configFlag)
+			return nil
+		}
+		if command.Name == "theme" {
+			// The look is local, so it isn't forwarded to the leader
+			if len(command.Args) != 1 {
+				return errors.New("usage: theme <name>")
+			}
+			previous := *themeFlag
+			*themeFlag = command.Args[0]
+			if err := reapply(); err != nil {
+				*themeFlag = previous
+				slog.Warn("applying theme", "theme", command.Args[0], "err", err)
+				return err
+			}
+			return nil
+		}
+		if leader != nil && !PeerCommands[command.Name] {
+			leader.Forward(command)
+			return nil
+		}
+		if err := state.Apply(command); err != nil {
+			slog.Warn("applying command", "command", command.String(), "err", err)
+			return err
+		}
+		if !PeerCommands[command.Name] {
+			peers.Send(state.Snapshot())
+		}
+		return nil
+	}
 
-	// Main loop to update state and display pomodoro time
+	// applyBatch applies a batch, which the leader applies as a whole when following one
+	applyBatch := func(command Command) ([]string, bool) {
+		if leader != nil && len(command.Batch) > 1 {
+			results, err := state.CheckBatch(command.Batch)
+			if err == nil {
+				leader.Forward(command)
+			}
+			return results, err == nil
+		}
+		results, failed := state.ApplyBatch(command, dispatch)
+		if failed > 0 {
+			peers.Send(state.Snapshot())
+		}
+		return results, failed != 0
+	}
+
+	// refresh updates the outputs with the state
+	refresh := func() {
+		for _, output := range outputs {
+			if err := output.Refresh(state); err != nil {
+				slog.Error("refreshing output", "output", output.Name(), "err", err)
+			}
+		}
+	}
+
+	// Main loop: every event (signal, tick, timer, command or render request)
+	// is handled in turn, and the outputs are only refreshed when it changed
+	// the state or what it looks like. The initial state is shown right away,
+	// as a paused timer has no ticks.
+	refresh()
 	for {
+		changed := true
 		select {
-		case <-state.Ticker.C:
-			if state.Paused {
-				state.Inc(1 * time.Second)
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				changed = dispatch(Command{Name: "reload"}) == nil
+				break
+			}
+			slog.Info("shutting down", "signal", sig)
+			if journal != nil {
+				if err := journal.Save(state, true); err != nil {
+					slog.Error("saving journal", "path", journal.Path, "err", err)
+				}
 			}
+			integrations.Close()
+			if statusFiles != nil {
+				if err := statusFiles.Remove(); err != nil {
+					slog.Warn("removing status files", "dir", statusFiles.Dir, "err", err)
+				}
+			}
+			return
+		case <-heartbeat:
+			if err := statusFiles.Touch(); err != nil {
+				slog.Warn("touching status files", "dir", statusFiles.Dir, "err", err)
+			}
+			changed = false
+		case <-state.Ticker.C:
+			state.FollowSchedule()
 		case <-state.Timer.C:
-			if !state.Paused {
-				state.Toggle()
-				cmd.Run()
+			status := state.Status
+			if state.Finish() {
+				notifier.Title, notifier.Message = PeriodEndMessage(Event{Status: status, Task: state.Task})
+				integrations.TimerEnd(status, state)
 			}
-		case <-pauseChannel:
-			state.Pause()
-		case <-toggleChannel:
-			state.Toggle()
-		case inc = <-incChannel:
-			state.Inc(inc)
+		case <-Renders:
+		case command := <-commands:
+			if command.Name == "batch" {
+				var results []string
+				results, changed = applyBatch(command)
+				if command.Reply != nil {
+					for _, result := range results {
+						command.Reply <- result
+					}
+					close(command.Reply)
+				}
+			} else if command.Reply != nil {
+				// The new subscriber already received the current state
+				changed = false
+				if err := subscribers.Add(command, state); err != nil {
+					slog.Warn("subscribing", "err", err)
+				}
+			} else {
+				changed = dispatch(command) == nil
+			}
+		}
+
+		state.UpdateReminders()
+		state.Reschedule()
+		if changed {
+			refresh()
 		}
-		statusStr := state.String()
-		fmt.Println(statusStr)
 	}
 }
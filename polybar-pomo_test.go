@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{"25m", 25 * time.Minute, false},
+		{"1m30s", 90 * time.Second, false},
+		{"90s", 90 * time.Second, false},
+		{"25", 25 * time.Minute, false},
+		{"0.5", 30 * time.Second, false},
+		{"1.25", 75 * time.Second, false},
+		{"-5", -5 * time.Minute, false},
+		{"", 0, true},
+		{"5 minutes", 0, true},
+		{"m", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+		{"-Inf", 0, true},
+		{"1e30", 0, true},
+		{"10081", 0, true},
+		{"10080", 7 * 24 * time.Hour, false},
+		{"169h", 0, true},
+		{"-169h", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseDuration(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("ParseDuration(%q) error = %v, want error %t", test.value, err, test.err)
+		} else if got != test.want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseBatch(t *testing.T) {
+	tests := []struct {
+		message string
+		want    Command
+	}{
+		{"", Command{}},
+		{" ; \n ;", Command{}},
+		{"pause", Command{Name: "pause", Args: []string{}}},
+		{"TASK writing docs", Command{Name: "task", Args: []string{"writing", "docs"}}},
+		{"pause;", Command{Name: "pause", Args: []string{}}},
+		{"task review; inc 5m", Command{Name: "batch", Batch: []Command{
+			{Name: "task", Args: []string{"review"}},
+			{Name: "inc", Args: []string{"5m"}},
+		}}},
+		{"pause\n\ntoggle;restart", Command{Name: "batch", Batch: []Command{
+			{Name: "pause", Args: []string{}},
+			{Name: "toggle", Args: []string{}},
+			{Name: "restart", Args: []string{}},
+		}}},
+	}
+	for _, test := range tests {
+		if got := ParseBatch(test.message); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseBatch(%q) = %+v, want %+v", test.message, got, test.want)
+		}
+	}
+}
+
+func TestReadRequest(t *testing.T) {
+	tests := []struct {
+		request string
+		want    string
+		err     error
+	}{
+		{"toggle\n", "toggle\n", nil},
+		{"toggle", "toggle", nil},
+		{"toggle\nskip\n", "toggle\nskip\n", nil},
+		{"merge " + strings.Repeat("A", MaxRequest/2) + "\n", "merge " + strings.Repeat("A", MaxRequest/2) + "\n", nil},
+		{strings.Repeat("A", MaxRequest+1), "", ErrRequestTooLong},
+		{strings.Repeat("A", MaxRequest) + "\n", "", ErrRequestTooLong},
+		{"", "", io.EOF},
+	}
+	for _, test := range tests {
+		got, err := ReadRequest(strings.NewReader(test.request))
+		if got != test.want || !errors.Is(err, test.err) {
+			t.Errorf("ReadRequest(%.20q) = %.20q, %v, want %.20q, %v", test.request, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestApplyBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		batch    string
+		results  []string
+		recorded int
+		status   PomodoroStatus
+	}{
+		{"applied", "toggle force; skip", []string{"ok", "ok"}, 2, Work},
+		{"reverted", "toggle force; skip; bogus", []string{"error: reverted", "error: reverted", "error: unknown command"}, 0, Work},
+		{"unbatchable", "toggle force; reload", []string{"error: skipped", "error: reload can't be part of a batch"}, 0, Work},
+	}
+	for _, test := range tests {
+		state := NewPomodoro(Work, false)
+		state.History = &History{Path: filepath.Join(t.TempDir(), "history.jsonl")}
+		dispatch := func(cmd Command) error {
+			state.Started = state.Started.Add(-time.Minute) // Every period ran long enough to be recorded
+			return state.Apply(cmd)
+		}
+
+		results, _ := state.ApplyBatch(ParseBatch(test.batch), dispatch)
+		if len(results) != len(test.results) {
+			t.Fatalf("%s: results = %q, want %q", test.name, results, test.results)
+		}
+		for i, result := range results {
+			if !strings.HasPrefix(result, test.results[i]) {
+				t.Errorf("%s: results = %q, want %q", test.name, results, test.results)
+				break
+			}
+		}
+		sessions, err := state.History.Load()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+		if len(sessions) != test.recorded {
+			t.Errorf("%s: recorded %d sessions, want %d", test.name, len(sessions), test.recorded)
+		}
+		if state.Status != test.status || state.Deferred != nil {
+			t.Errorf("%s: status = %s (deferred %d), want %s", test.name, state.Status, len(state.Deferred), test.status)
+		}
+	}
+}
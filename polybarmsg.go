@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// PolybarActions triggers polybar actions with polybar-msg when entering a
+// state, so that other modules (e.g. an ipc module toggling do not disturb)
+// can react to the pomodoro
+type PolybarActions struct {
+	Actions map[string]string // Action by class (work, rest, paused or exit), e.g. #dnd.hook.0
+
+	last string // Class of the action triggered last
+}
+
+// Name returns the name of the integration
+func (polybar *PolybarActions) Name() string {
+	return "polybar-msg"
+}
+
+// Handle triggers the action of the state after the event, or the exit one on shutdown
+func (polybar *PolybarActions) Handle(event Event) error {
+	class := event.Class()
+	if event.Name == EventShutdown {
+		class = "exit"
+	}
+	action, ok := polybar.Actions[class]
+	if class == polybar.last || !ok {
+		return nil
+	}
+	polybar.last = class
+
+	output, err := exec.Command("polybar-msg", "action", action).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("polybar-msg action %s: %w: %s", action, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
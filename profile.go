@@ -0,0 +1,239 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultProfile is the name of the settings given on the command line and in the config file
+const DefaultProfile = "default"
+
+// OffProfile is the name of the profile of the days off: the default
+// settings, with the timer paused when switching to it and nothing recorded
+const OffProfile = "off"
+
+// Profile is a named set of timer settings, switched at runtime with the profile command
+type Profile struct {
+	Name      string
+	Work      time.Duration
+	Rest      time.Duration
+	AutoStart [2]bool
+	Ramps     map[PomodoroStatus][]string
+	Colors    map[string]string
+	Goal      int // Pomodoros to complete in a day, 0 for none
+}
+
+// CurrentProfile captures the settings in use under the given name
+func (state *PomodoroState) CurrentProfile(name string) Profile {
+	return Profile{
+		Name:      name,
+		Work:      WorkDuration,
+		Rest:      RestDuration,
+		AutoStart: state.AutoStart,
+		Ramps:     state.Ramps,
+		Colors:    state.MarkupColors,
+		Goal:      state.Goal,
+	}
+}
+
+// LoadProfile reads the profile file dir/name, made of "key = value" lines
+// like the config file, on top of the base settings. The keys are w, r,
+// auto-start-work, auto-start-rest, work-ramp, rest-ramp, markup-colors and goal.
+func LoadProfile(dir, name string, base Profile) (Profile, error) {
+	if name == DefaultProfile {
+		return base, nil
+	} else if name == OffProfile {
+		base.Name = OffProfile
+		return base, nil
+	}
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return Profile{}, fmt.Errorf("invalid profile name %q", name)
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	work, rest := DurationFlag(base.Work), DurationFlag(base.Rest)
+	flags.Var(&work, "w", "")
+	flags.Var(&rest, "r", "")
+	autoWork := flags.Bool("auto-start-work", base.AutoStart[Work], "")
+	autoRest := flags.Bool("auto-start-rest", base.AutoStart[Rest], "")
+	workRamp := flags.String("work-ramp", strings.Join(base.Ramps[Work], ","), "")
+	restRamp := flags.String("rest-ramp", strings.Join(base.Ramps[Rest], ","), "")
+	colors := flags.String("markup-colors", "", "")
+	goal := flags.Int("goal", base.Goal, "")
+	if err := LoadConfig(flags, filepath.Join(dir, name), nil); err != nil {
+		return Profile{}, fmt.Errorf("loading profile %s: %w", name, err)
+	}
+
+	profile := Profile{
+		Name:   name,
+		Work:   time.Duration(work),
+		Rest:   time.Duration(rest),
+		Ramps:  make(map[PomodoroStatus][]string),
+		Colors: base.Colors,
+		Goal:   *goal,
+	}
+	profile.AutoStart[Work], profile.AutoStart[Rest] = *autoWork, *autoRest
+	if *workRamp != "" {
+		profile.Ramps[Work] = strings.Split(*workRamp, ",")
+	}
+	if *restRamp != "" {
+		profile.Ramps[Rest] = strings.Split(*restRamp, ",")
+	}
+	if *colors != "" {
+		profile.Colors = ParseMapping(*colors)
+	}
+	return profile, nil
+}
+
+// SetProfile switches to the profile with the given name. The current period
+// keeps the time already elapsed, with its duration changed to the profile one.
+func (state *PomodoroState) SetProfile(name string) error {
+	profile, err := LoadProfile(state.ProfileDir, name, state.BaseProfile)
+	if err != nil {
+		return err
+	}
+
+	delta := map[PomodoroStatus]time.Duration{Work: profile.Work, Rest: profile.Rest}[state.Status] - GetDuration(state.Status)
+	WorkDuration, RestDuration = profile.Work, profile.Rest
+	state.AutoStart, state.Ramps, state.MarkupColors = profile.AutoStart, profile.Ramps, profile.Colors
+	state.Profile, state.Goal = profile.Name, profile.Goal
+	if delta != 0 {
+		state.Inc(delta)
+	}
+	if profile.Name == OffProfile && !state.Paused {
+		state.Pause()
+	}
+	return nil
+}
+
+// ProfileSlot switches to a profile at a time of day, on some days of the week
+type ProfileSlot struct {
+	At      time.Duration // Time since midnight
+	Days    [7]bool       // Days of the week the slot applies to, by time.Weekday
+	Profile string
+}
+
+// ProfileSchedule lists the profile switches of the week, sorted by time of day
+type ProfileSchedule []ProfileSlot
+
+// Weekdays are the names of the days of the schedule, by time.Weekday
+var Weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseProfileSchedule parses a comma-separated list of time=profile pairs,
+// each one optionally preceded by the days it applies to, as a day, a range
+// or days joined by '+', e.g. 09:00=email,mon-thu 10:00=deepwork,fri 10:00=light,sat+sun 00:00=off
+func ParseProfileSchedule(list string) (ProfileSchedule, error) {
+	var schedule ProfileSchedule
+	for _, pair := range strings.Split(list, ",") {
+		at, name, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid schedule entry %q (expected [days] HH:MM=profile)", pair)
+		}
+		slot := ProfileSlot{Profile: strings.TrimSpace(name)}
+		if days, clock, found := strings.Cut(strings.TrimSpace(at), " "); found {
+			var err error
+			if slot.Days, err = parseDays(days); err != nil {
+				return nil, err
+			}
+			at = clock
+		} else {
+			slot.Days = [7]bool{true, true, true, true, true, true, true}
+		}
+		clock, err := time.Parse("15:04", strings.TrimSpace(at))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule time %q (expected HH:MM)", at)
+		}
+		slot.At = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+		schedule = append(schedule, slot)
+	}
+	sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].At < schedule[j].At })
+	return schedule, nil
+}
+
+// parseDays parses the days of a schedule slot, e.g. fri, mon-thu or sat+sun
+func parseDays(value string) ([7]bool, error) {
+	var days [7]bool
+	for _, part := range strings.Split(strings.ToLower(value), "+") {
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			to = from
+		}
+		first, last := weekday(from), weekday(to)
+		if first < 0 || last < 0 {
+			return days, fmt.Errorf("invalid schedule days %q (expected e.g. fri, mon-thu or sat+sun)", value)
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// weekday returns the index of a day name in Weekdays, -1 when unknown
+func weekday(name string) int {
+	for i, day := range Weekdays {
+		if name == day {
+			return i
+		}
+	}
+	return -1
+}
+
+// Start returns the start of the last slot started at the given time, on
+// the same day or the days before, and its profile
+func (schedule ProfileSchedule) Start(t time.Time) (time.Time, string) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for days := 0; days <= 7; days++ {
+		day := midnight.AddDate(0, 0, -days)
+		for i := len(schedule) - 1; i >= 0; i-- {
+			slot := schedule[i]
+			if start := day.Add(slot.At); slot.Days[day.Weekday()] && !start.After(t) {
+				return start, slot.Profile
+			}
+		}
+	}
+	return time.Time{}, ""
+}
+
+// At returns the profile scheduled at the given time, the one of the last slot started
+func (schedule ProfileSchedule) At(t time.Time) string {
+	_, profile := schedule.Start(t)
+	return profile
+}
+
+// Until returns the time until the next slot starts
+func (schedule ProfileSchedule) Until(t time.Time) time.Duration {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for days := 0; days <= 7; days++ {
+		day := midnight.AddDate(0, 0, days)
+		for _, slot := range schedule {
+			if next := day.Add(slot.At); slot.Days[day.Weekday()] && next.After(t) {
+				return next.Sub(t)
+			}
+		}
+	}
+	return 24 * time.Hour
+}
+
+// FollowSchedule switches to the scheduled profile when a new slot starts,
+// so a profile picked by hand is kept until the next one
+func (state *PomodoroState) FollowSchedule() {
+	start, name := state.Schedule.Start(time.Now())
+	if name == "" || start.Equal(state.Scheduled) {
+		return
+	}
+	state.Scheduled = start
+	if err := state.SetProfile(name); err != nil {
+		slog.Warn("switching to the scheduled profile", "profile", name, "err", err)
+		return
+	}
+	slog.Info("switched to the scheduled profile", "profile", name)
+}
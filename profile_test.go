@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var everyDay = [7]bool{true, true, true, true, true, true, true}
+
+func TestParseProfileSchedule(t *testing.T) {
+	tests := []struct {
+		list string
+		want ProfileSchedule
+		err  bool
+	}{
+		{"09:00=email", ProfileSchedule{{At: 9 * time.Hour, Days: everyDay, Profile: "email"}}, false},
+		{"18:30=default, 09:00 = email,10:00=deepwork", ProfileSchedule{
+			{At: 9 * time.Hour, Days: everyDay, Profile: "email"},
+			{At: 10 * time.Hour, Days: everyDay, Profile: "deepwork"},
+			{At: 18*time.Hour + 30*time.Minute, Days: everyDay, Profile: "default"},
+		}, false},
+		{"mon-thu 10:00=deepwork,sat+sun 00:00=off", ProfileSchedule{
+			{At: 0, Days: [7]bool{true, false, false, false, false, false, true}, Profile: OffProfile},
+			{At: 10 * time.Hour, Days: [7]bool{false, true, true, true, true, false, false}, Profile: "deepwork"},
+		}, false},
+		{"09:00", nil, true},
+		{"9am=email", nil, true},
+		{"25:00=email", nil, true},
+		{"someday 09:00=email", nil, true},
+	}
+	for _, test := range tests {
+		got, err := ParseProfileSchedule(test.list)
+		if (err != nil) != test.err {
+			t.Errorf("ParseProfileSchedule(%q) error = %v, want error %t", test.list, err, test.err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseProfileSchedule(%q) = %+v, want %+v", test.list, got, test.want)
+		}
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		value string
+		want  [7]bool
+		err   bool
+	}{
+		{"fri", [7]bool{5: true}, false},
+		{"Mon-Thu", [7]bool{1: true, 2: true, 3: true, 4: true}, false},
+		{"sat+sun", [7]bool{0: true, 6: true}, false},
+		{"fri-mon", [7]bool{0: true, 1: true, 5: true, 6: true}, false}, // Across the weekend
+		{"mon+wed-thu", [7]bool{1: true, 3: true, 4: true}, false},
+		{"monday", [7]bool{}, true},
+		{"mon-", [7]bool{}, true},
+		{"", [7]bool{}, true},
+	}
+	for _, test := range tests {
+		got, err := parseDays(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("parseDays(%q) error = %v, want error %t", test.value, err, test.err)
+		} else if !test.err && got != test.want {
+			t.Errorf("parseDays(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestProfileScheduleAt(t *testing.T) {
+	schedule, err := ParseProfileSchedule("09:00=email,mon-fri 10:00=deepwork,18:00=default,sat+sun 00:00=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wednesday := time.Date(2024, time.March, 6, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{wednesday, "default"}, // Last slot of the previous day
+		{wednesday.Add(9 * time.Hour), "email"},
+		{wednesday.Add(10 * time.Hour), "deepwork"},
+		{wednesday.Add(23 * time.Hour), "default"},
+		{wednesday.AddDate(0, 0, 3).Add(8 * time.Hour), OffProfile}, // Saturday morning
+		{wednesday.AddDate(0, 0, 3).Add(10 * time.Hour), "email"},   // No deepwork on saturdays
+	}
+	for _, test := range tests {
+		if got := schedule.At(test.t); got != test.want {
+			t.Errorf("At(%s) = %q, want %q", test.t.Format("Mon 15:04"), got, test.want)
+		}
+	}
+	if got := (ProfileSchedule{}).At(wednesday); got != "" {
+		t.Errorf("empty schedule At = %q, want none", got)
+	}
+}
+
+func TestProfileScheduleUntil(t *testing.T) {
+	schedule, err := ParseProfileSchedule("09:00=email,mon-fri 10:00=deepwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wednesday := time.Date(2024, time.March, 6, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		t    time.Time
+		want time.Duration
+	}{
+		{wednesday.Add(8 * time.Hour), time.Hour},
+		{wednesday.Add(9 * time.Hour), time.Hour},
+		{wednesday.Add(10 * time.Hour), 23 * time.Hour},
+		{wednesday.AddDate(0, 0, 3).Add(9*time.Hour + 30*time.Minute), 23*time.Hour + 30*time.Minute}, // Saturday
+	}
+	for _, test := range tests {
+		if got := schedule.Until(test.t); got != test.want {
+			t.Errorf("Until(%s) = %s, want %s", test.t.Format("Mon 15:04"), got, test.want)
+		}
+	}
+}
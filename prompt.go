@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PromptLine formats the state for the prompt file, as the class, the end of
+// the period as a Unix time and the remaining seconds, e.g. "work 1714640000 1500",
+// so the prompt computes the remaining time without asking the daemon
+func PromptLine(state *PomodoroState) string {
+	remaining := state.Remaining().Round(time.Second)
+	return fmt.Sprintf("%s %d %d", state.Class(), time.Now().Add(remaining).Unix(), int(remaining.Seconds()))
+}
+
+// Prompt prints a segment for shell prompts (PS1, starship custom modules)
+// from the prompt file, or nothing when the daemon is not running
+func Prompt(args []string) error {
+	flags := flag.NewFlagSet("prompt", flag.ExitOnError)
+	fileFlag := flags.String("file", filepath.Join(StatusDir(), "prompt"), "Prompt file, written by the daemon with -status-file")
+	formatFlag := flags.String("format", "%i %m", "Segment format, where %i is the icon, %m the remaining minutes, %t the remaining MM:SS and %c the class")
+	flags.Parse(args)
+
+	info, err := os.Stat(*fileFlag)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(*fileFlag)
+	if err != nil {
+		return nil
+	}
+	var class string
+	var end, seconds int64
+	if _, err := fmt.Sscan(string(data), &class, &end, &seconds); err != nil {
+		return fmt.Errorf("%s: %w", *fileFlag, err)
+	}
+
+	// The daemon touches the file while it runs, even when paused
+	if time.Since(info.ModTime()) > StatusStale {
+		return nil
+	}
+	remaining := time.Duration(seconds) * time.Second
+	if class != "paused" {
+		remaining = time.Until(time.Unix(end, 0))
+	}
+	icon := map[string]string{"work": TomatoEmoji, "rest": RestEmoji, "paused": PauseEmoji}[class]
+
+	minutes := formatMinutes(max(0, remaining+time.Minute-time.Nanosecond).Truncate(time.Minute))
+	fmt.Println(strings.NewReplacer("%i", icon, "%m", minutes, "%t", formatClock(remaining), "%c", class).Replace(*formatFlag))
+	return nil
+}
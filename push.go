@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PushoverAPI is the endpoint of the Pushover messages API
+const PushoverAPI = "https://api.pushover.net/1/messages.json"
+
+// Pusher sends push notifications through a service
+type Pusher interface {
+	Push(title, message string) error
+}
+
+// Push sends a push notification when the timer of a period ends, with the
+// same message as the desktop notification
+type Push struct {
+	Service string
+	Pusher  Pusher
+}
+
+// Name returns the name of the push notification service
+func (push *Push) Name() string {
+	return push.Service
+}
+
+// Handle pushes the end of the timer
+func (push *Push) Handle(event Event) error {
+	if event.Name != EventTimerEnd {
+		return nil
+	}
+	return push.Pusher.Push(PeriodEndMessage(event))
+}
+
+// Gotify sends push notifications to a Gotify server
+type Gotify struct {
+	URL   string // Server URL, e.g. https://gotify.example.com
+	Token string // Token of the application the messages are sent as
+}
+
+// Push sends the notification
+func (gotify *Gotify) Push(title, message string) error {
+	body, err := json.Marshal(map[string]any{"title": title, "message": message, "priority": 5})
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(gotify.URL, "/") + "/message"
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Gotify-Key", gotify.Token)
+	return pushRequest(request)
+}
+
+// Pushover sends push notifications through Pushover
+type Pushover struct {
+	Token string // Token of the Pushover application
+	User  string // User (or group) key of the recipients
+}
+
+// Push sends the notification
+func (pushover *Pushover) Push(title, message string) error {
+	form := url.Values{"token": {pushover.Token}, "user": {pushover.User}, "title": {title}, "message": {message}}
+	request, err := http.NewRequest(http.MethodPost, PushoverAPI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return pushRequest(request)
+}
+
+func pushRequest(request *http.Request) error {
+	client := &http.Client{Timeout: IntegrationTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", request.Method, request.URL.Redacted(), response.Status)
+	}
+	return nil
+}
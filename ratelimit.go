@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	MaxConnections = 64                     // Concurrent connections accepted by each listener, 0 for unlimited
+	Limiter        = NewRateLimiter(10, 20) // Commands accepted by client
+)
+
+// RateLimiter is a token bucket by client, refilled at Rate commands per
+// second up to Burst commands
+type RateLimiter struct {
+	Rate  float64 // 0 disables the limit
+	Burst float64
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter returns a rate limiter allowing rate commands per second on
+// average and bursts of burst commands
+func NewRateLimiter(rate, burst float64) *RateLimiter {
+	return &RateLimiter{Rate: rate, Burst: burst, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes n commands from the bucket of the client, returning false when
+// it holds fewer
+func (limiter *RateLimiter) Allow(client string, n int) bool {
+	if limiter.Rate <= 0 {
+		return true
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+	b, ok := limiter.buckets[client]
+	if !ok {
+		limiter.prune(now)
+		b = &bucket{tokens: limiter.Burst, updated: now}
+		limiter.buckets[client] = b
+	}
+	b.tokens = min(limiter.Burst, b.tokens+now.Sub(b.updated).Seconds()*limiter.Rate)
+	b.updated = now
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// prune forgets the clients whose bucket refilled, the mutex being held
+func (limiter *RateLimiter) prune(now time.Time) {
+	full := time.Duration(limiter.Burst / limiter.Rate * float64(time.Second))
+	for client, b := range limiter.buckets {
+		if now.Sub(b.updated) > full {
+			delete(limiter.buckets, client)
+		}
+	}
+}
+
+// ClientKey identifies the client of a connection for rate limiting: its user
+// id on the Unix socket, its IP address on network listeners
+func ClientKey(conn net.Conn) string {
+	if unixConn, ok := conn.(*net.UnixConn); ok {
+		if uid, err := PeerUID(unixConn); err == nil {
+			return fmt.Sprintf("uid:%d", uid)
+		}
+	}
+	return remoteHost(conn.RemoteAddr().String())
+}
+
+// remoteHost returns the host of a remote address, or the address itself when it has no port
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := NewRateLimiter(0.001, 3) // Refilling slower than the test runs
+	steps := []struct {
+		client string
+		n      int
+		want   bool
+	}{
+		{"a", 1, true},
+		{"a", 2, true},
+		{"a", 1, false}, // Burst used
+		{"b", 3, true},  // Buckets are by client
+		{"b", 1, false},
+		{"c", 4, false}, // Larger than the burst
+		{"c", 3, true},  // Nothing taken by the refused batch
+	}
+	for i, step := range steps {
+		if got := limiter.Allow(step.client, step.n); got != step.want {
+			t.Errorf("step %d: Allow(%q, %d) = %t, want %t", i, step.client, step.n, got, step.want)
+		}
+	}
+
+	// Refilled over time, up to the burst
+	limiter.buckets["a"].updated = time.Now().Add(-1500 * time.Second)
+	if !limiter.Allow("a", 1) {
+		t.Error("Allow after refilling = false, want true")
+	}
+	limiter.buckets["a"].updated = time.Now().Add(-time.Hour)
+	if limiter.Allow("a", 4) || !limiter.Allow("a", 3) {
+		t.Error("refilled bucket doesn't hold the burst")
+	}
+
+	unlimited := NewRateLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if !unlimited.Allow("a", 10) {
+			t.Fatal("Allow with a zero rate = false, want true")
+		}
+	}
+}
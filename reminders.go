@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reminder is a recurring reminder running alongside the pomodoros, e.g. to
+// stand up or drink water. Its name is notified and shown in the output
+// while it is due.
+type Reminder struct {
+	Name  string
+	Every time.Duration
+
+	Elapsed  time.Duration // Running time counted since the reminder was last due
+	Due      bool          // Whether it is shown, until dismissed
+	Notified bool          // Whether the notification of the due reminder was sent
+}
+
+// ParseReminders parses a comma-separated list of name=interval pairs, e.g. 🧍=45m,💧=1h
+func ParseReminders(list string) ([]*Reminder, error) {
+	var reminders []*Reminder
+	for _, pair := range strings.Split(list, ",") {
+		name, every, found := strings.Cut(pair, "=")
+		if name = strings.TrimSpace(name); !found || name == "" {
+			return nil, fmt.Errorf("invalid reminder %q (expected name=interval)", pair)
+		}
+		interval, err := ParseDuration(strings.TrimSpace(every))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid interval of reminder %q", name)
+		}
+		reminders = append(reminders, &Reminder{Name: name, Every: interval})
+	}
+	return reminders, nil
+}
+
+// QuietHours is a time of day without reminders, possibly across midnight
+type QuietHours struct {
+	From, To time.Duration // Time since midnight, equal for no quiet hours
+}
+
+// ParseQuietHours parses a time range, e.g. 22:00-08:00
+func ParseQuietHours(value string) (QuietHours, error) {
+	from, to, found := strings.Cut(value, "-")
+	if !found {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q (expected HH:MM-HH:MM)", value)
+	}
+	var hours QuietHours
+	for _, bound := range []struct {
+		text  string
+		value *time.Duration
+	}{{from, &hours.From}, {to, &hours.To}} {
+		clock, err := time.Parse("15:04", strings.TrimSpace(bound.text))
+		if err != nil {
+			return QuietHours{}, fmt.Errorf("invalid quiet hours time %q (expected HH:MM)", bound.text)
+		}
+		*bound.value = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+	}
+	return hours, nil
+}
+
+// Contains returns whether a time is within the quiet hours
+func (hours QuietHours) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	since := t.Sub(midnight)
+	if hours.From <= hours.To {
+		return since >= hours.From && since < hours.To
+	}
+	return since >= hours.From || since < hours.To
+}
+
+// UpdateReminders counts the time the timer ran since the previous update,
+// pauses and quiet hours excluded, and marks the reminders reaching their
+// interval as due
+func (state *PomodoroState) UpdateReminders() {
+	now := time.Now()
+	if len(state.Reminders) == 0 || state.Paused || state.QuietHours.Contains(now) {
+		state.remindersAt = time.Time{}
+		return
+	}
+	if !state.remindersAt.IsZero() {
+		for _, reminder := range state.Reminders {
+			if reminder.Elapsed += now.Sub(state.remindersAt); reminder.Elapsed >= reminder.Every {
+				reminder.Elapsed, reminder.Due, reminder.Notified = 0, true, false
+			}
+		}
+	}
+	state.remindersAt = now
+}
+
+// SetReminders replaces the reminders, keeping the time counted by the ones
+// with the same name
+func (state *PomodoroState) SetReminders(reminders []*Reminder) {
+	for _, reminder := range reminders {
+		for _, previous := range state.Reminders {
+			if previous.Name == reminder.Name {
+				reminder.Elapsed, reminder.Due, reminder.Notified = min(previous.Elapsed, reminder.Every), previous.Due, previous.Notified
+			}
+		}
+	}
+	state.Reminders = reminders
+}
+
+// Dismiss hides the due reminder with the given name, or all of them
+func (state *PomodoroState) Dismiss(name string) error {
+	found := false
+	for _, reminder := range state.Reminders {
+		if name == "" || reminder.Name == name {
+			reminder.Due, found = false, true
+		}
+	}
+	if !found && name == "" {
+		return errors.New("no reminders are set")
+	} else if !found {
+		return fmt.Errorf("unknown reminder %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Renderer formats the pomodoro state into a single line of output
+type Renderer func(state *PomodoroState) string
+
+// Renderers maps the output format names to their renderer
+var Renderers = map[string]Renderer{
+	"polybar": (*PomodoroState).String,
+	"waybar":  RenderWaybar,
+	"json":    RenderJSON,
+	"conky":   RenderConky,
+	"genmon":  RenderGenmon,
+	"i3rs":    RenderI3rs,
+	"eww":     RenderEww,
+}
+
+// Class returns the name of the current state, suitable for styling
+func (state *PomodoroState) Class() string {
+	if state.Paused {
+		return "paused"
+	}
+	return state.Status.String()
+}
+
+// Percentage returns the remaining time as a percentage of the period duration
+func (state *PomodoroState) Percentage() int {
+	duration := GetDuration(state.Status)
+	if duration <= 0 {
+		return 0
+	}
+	remaining := state.Remaining().Round(time.Second)
+	percentage := int(100 * remaining / duration)
+	return max(0, min(100, percentage))
+}
+
+// Markup languages of the output
+const (
+	MarkupNone    = "none"
+	MarkupPolybar = "polybar" // Polybar format tags, e.g. %{F#ff5555}text%{F-}
+	MarkupPango   = "pango"   // Pango markup, e.g. for waybar, eww or GTK widgets
+	MarkupConky   = "conky"   // Conky colour variables, e.g. ${color #ff5555}text${color}
+)
+
+// DefaultMarkupColors are the foreground colours of the states in markup output
+const DefaultMarkupColors = "work=#ff5555,rest=#50fa7b,paused=#f1fa8c"
+
+// Markup colours the text by state in the configured markup language
+func (state *PomodoroState) Markup(text string) string {
+	return state.markupAs(state.MarkupMode, text)
+}
+
+// markupAs colours the text by state in the given markup language
+func (state *PomodoroState) markupAs(mode, text string) string {
+	color, ok := state.MarkupColors[state.Class()]
+	if ending, found := state.MarkupColors["ending"]; found && state.Ending() {
+		color, ok = ending, true
+	}
+	switch {
+	case mode == MarkupPango && !ok:
+		return html.EscapeString(text)
+	case mode == MarkupConky && !ok:
+		return strings.ReplaceAll(text, "$", "$$")
+	case !ok:
+		return text
+	}
+	switch mode {
+	case MarkupPolybar:
+		return "%{F" + color + "}" + text + "%{F-}"
+	case MarkupPango:
+		return `<span foreground="` + html.EscapeString(color) + `">` + html.EscapeString(text) + "</span>"
+	case MarkupConky:
+		return "${color " + color + "}" + strings.ReplaceAll(text, "$", "$$") + "${color}"
+	}
+	return text
+}
+
+// RenderConky formats the state with conky colour variables, whatever the
+// markup of the other outputs, to be embedded with ${execpi} or ${catp}
+func RenderConky(state *PomodoroState) string {
+	return state.markupAs(MarkupConky, state.Text())
+}
+
+// GenmonClick returns the command run by xfce4-genmon-plugin when the text is clicked
+func GenmonClick() string {
+	return "sh -c " + shellQuote("echo toggle | nc -w 1 -U "+shellQuote(SocketPath))
+}
+
+// shellQuote quotes a string as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// RenderGenmon formats the state as the XML tags of xfce4-genmon-plugin,
+// where the text is pango markup
+func RenderGenmon(state *PomodoroState) string {
+	return "<txt>" + state.markupAs(MarkupPango, state.Text()) + "</txt>" +
+		"<tool>" + html.EscapeString(fmt.Sprintf("Pomodoro (%s, %s profile)", state.Class(), state.Profile)) + "</tool>" +
+		"<txtclick>" + GenmonClick() + "</txtclick>"
+}
+
+// RenderWaybar formats the state as a waybar custom module JSON object
+func RenderWaybar(state *PomodoroState) string {
+	output, _ := json.Marshal(struct {
+		Text       string `json:"text"`
+		Alt        string `json:"alt"`
+		Tooltip    string `json:"tooltip"`
+		Class      string `json:"class"`
+		Percentage int    `json:"percentage"`
+	}{
+		Text:       state.String(),
+		Alt:        state.Class(),
+		Tooltip:    fmt.Sprintf("Pomodoro (%s, %s profile)", state.Class(), state.Profile),
+		Class:      state.Class(),
+		Percentage: state.Percentage(),
+	})
+	return string(output)
+}
+
+// RenderI3rs formats the state as the JSON object of i3status-rust custom
+// blocks (with json = true), whose state selects the theme colours: Warning
+// during work, Good during rest, Idle when paused and Critical in overtime
+func RenderI3rs(state *PomodoroState) string {
+	blockState := map[string]string{"work": "Warning", "rest": "Good", "paused": "Idle"}[state.Class()]
+	if !state.Paused && state.End.Before(time.Now()) {
+		blockState = "Critical"
+	}
+	// No icon, the text starting with the one of the state already
+	output, _ := json.Marshal(struct {
+		State     string `json:"state"`
+		Text      string `json:"text"`
+		ShortText string `json:"short_text"`
+	}{
+		State:     blockState,
+		Text:      state.Text(),
+		ShortText: state.Clock(),
+	})
+	return string(output)
+}
+
+// RenderEww formats the state as a compact JSON object with the icon and the
+// time apart, for eww widgets following it with deflisten
+func RenderEww(state *PomodoroState) string {
+	output, _ := json.Marshal(struct {
+		Icon       string `json:"icon"`
+		Time       string `json:"time"`
+		Class      string `json:"class"`
+		Percentage int    `json:"percentage"`
+		Task       string `json:"task"`
+	}{
+		Icon:       state.Icon(),
+		Time:       state.Clock(),
+		Class:      state.Class(),
+		Percentage: state.Percentage(),
+		Task:       state.Task,
+	})
+	return string(output)
+}
+
+// RenderJSON formats the state as a generic JSON object
+func RenderJSON(state *PomodoroState) string {
+	output, _ := json.Marshal(struct {
+		Text       string `json:"text"`
+		Class      string `json:"class"`
+		Status     string `json:"status"`
+		Paused     bool   `json:"paused"`
+		Remaining  int    `json:"remaining"`
+		Duration   int    `json:"duration"`
+		Percentage int    `json:"percentage"`
+		Today      int    `json:"today"`
+		Profile    string `json:"profile"`
+	}{
+		Text:       state.String(),
+		Class:      state.Class(),
+		Status:     state.Status.String(),
+		Paused:     state.Paused,
+		Remaining:  int(state.Remaining().Round(time.Second).Seconds()),
+		Duration:   int(GetDuration(state.Status).Seconds()),
+		Percentage: state.Percentage(),
+		Today:      state.CompletedToday(),
+		Profile:    state.Profile,
+	})
+	return string(output)
+}
+
+// Subscriber is a client stream receiving the state rendered after every update
+type Subscriber struct {
+	Render Renderer
+	Stream chan string
+}
+
+// Subscribers are the renderers and followers connected to the daemon
+type Subscribers []Subscriber
+
+// Add registers the stream of a join or subscribe request and sends it the current state
+func (subscribers *Subscribers) Add(cmd Command, state *PomodoroState) error {
+	render := Renderer((*PomodoroState).Snapshot)
+	if cmd.Name == "subscribe" {
+		format := "polybar"
+		if len(cmd.Args) > 0 {
+			format = cmd.Args[0]
+		}
+		var ok bool
+		if render, ok = Renderers[format]; !ok {
+			close(cmd.Reply)
+			return fmt.Errorf("unknown output format %q", format)
+		}
+	}
+
+	*subscribers = append(*subscribers, Subscriber{Render: render, Stream: cmd.Reply})
+	cmd.Reply <- render(state)
+	return nil
+}
+
+// Name returns the name of the output
+func (subscribers *Subscribers) Name() string {
+	return "subscribers"
+}
+
+// Refresh publishes the state to the subscribers
+func (subscribers *Subscribers) Refresh(state *PomodoroState) error {
+	subscribers.Publish(state)
+	return nil
+}
+
+// Publish sends the rendered state to every subscriber, dropping the ones that stopped reading
+func (subscribers *Subscribers) Publish(state *PomodoroState) {
+	active := (*subscribers)[:0]
+	for _, subscriber := range *subscribers {
+		select {
+		case subscriber.Stream <- subscriber.Render(state):
+			active = append(active, subscriber)
+		default:
+			close(subscriber.Stream)
+		}
+	}
+	*subscribers = active
+}
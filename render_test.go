@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellQuote(t *testing.T) {
+	for _, s := range []string{"", "/tmp/polybar-pomo", "/run/user/1000/my socket", "it's", "'", "$(touch pwned); `id` \\"} {
+		output, err := exec.Command("sh", "-c", "printf %s "+shellQuote(s)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != s {
+			t.Errorf("shellQuote(%q) = %s, read back as %q", s, shellQuote(s), output)
+		}
+	}
+}
+
+func TestGenmonClick(t *testing.T) {
+	defer func(path string) { SocketPath = path }(SocketPath)
+	// words splits a command line as the shell does
+	words := func(command string) []string {
+		output, err := exec.Command("sh", "-c", `for word in `+command+`; do printf '%s\n' "$word"; done`).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	}
+	for _, socket := range []string{"/tmp/polybar-pomo", "/run/user/1000/my socket", "/tmp/it's; touch pwned"} {
+		SocketPath = socket
+		command := words(GenmonClick())
+		if len(command) != 3 || command[0] != "sh" || command[1] != "-c" {
+			t.Fatalf("GenmonClick with socket %q = %s, want a sh -c command", socket, GenmonClick())
+		}
+		script := words(strings.ReplaceAll(command[2], "|", ""))
+		if got := script[len(script)-1]; got != socket {
+			t.Errorf("GenmonClick with socket %q = %s, connecting to %q", socket, GenmonClick(), got)
+		}
+	}
+}
+
+func TestRenderI3rs(t *testing.T) {
+	state := func(status PomodoroStatus, paused bool, remaining time.Duration) *PomodoroState {
+		state := NewPomodoro(status, paused)
+		state.End = state.Frozen.Add(remaining)
+		if !paused {
+			// Rounded to the same second when rendered right away
+			state.End = time.Now().Add(remaining + 300*time.Millisecond)
+		}
+		return state
+	}
+	tests := []struct {
+		name  string
+		state *PomodoroState
+		want  string
+	}{
+		{"work", state(Work, false, 25*time.Minute), `{"state":"Warning","text":"🍅 25:00","short_text":"25:00"}`},
+		{"rest", state(Rest, false, 5*time.Minute), `{"state":"Good","text":"🏖 05:00","short_text":"05:00"}`},
+		{"paused", state(Work, true, 12*time.Minute), `{"state":"Idle","text":"⏸ 12:00","short_text":"12:00"}`},
+		{"overtime", state(Work, false, -2*time.Second), `{"state":"Critical","text":"🍅 +00:02","short_text":"+00:02"}`},
+	}
+	for _, test := range tests {
+		if got := RenderI3rs(test.state); got != test.want {
+			t.Errorf("%s: RenderI3rs = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
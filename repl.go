@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// ReplCommands are handled by the REPL itself instead of being sent to the daemon
+var ReplCommands = []string{"help", "quit"}
+
+// Repl runs an interactive prompt sending commands to a running daemon, with
+// command completion, history and a live status line
+func Repl(args []string) error {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	addrFlag := flags.String("addr", "unix://"+SocketPath, "Daemon address (e.g. tcp://127.0.0.1:7777)")
+	tokenFlag := flags.String("token", "", "Shared token of the daemon network listener")
+	flags.Parse(args)
+
+	repl := &ReplSession{Addr: *addrFlag, Token: *tokenFlag, Status: "?"}
+
+	old, err := makeRaw(syscall.Stdin)
+	if err != nil {
+		// Not a terminal: read plain lines without the live status line
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if !repl.Execute(scanner.Text()) {
+				break
+			}
+		}
+		return scanner.Err()
+	}
+	defer restoreTerminal(syscall.Stdin, old)
+
+	go func() {
+		err := Stream(repl.Addr, repl.message("subscribe"), repl.SetStatus)
+		repl.Println(fmt.Sprintf("lost connection to daemon: %v", err))
+	}()
+	repl.Redraw()
+	return repl.Run(bufio.NewReader(os.Stdin))
+}
+
+// ReplSession holds the input line and display of an interactive session
+type ReplSession struct {
+	Addr    string
+	Token   string
+	Status  string
+	Line    []rune
+	History []string
+
+	mutex sync.Mutex
+}
+
+// Run reads keys until the session ends
+func (repl *ReplSession) Run(reader *bufio.Reader) error {
+	historyIndex := 0
+	for {
+		key, _, err := reader.ReadRune()
+		if err != nil {
+			return err
+		}
+
+		repl.mutex.Lock()
+		switch key {
+		case 3, 4: // Ctrl-C, Ctrl-D
+			repl.mutex.Unlock()
+			fmt.Print("\r\n")
+			return nil
+		case '\r', '\n':
+			line := string(repl.Line)
+			repl.Line = nil
+			if strings.TrimSpace(line) != "" {
+				repl.History = append(repl.History, line)
+			}
+			historyIndex = len(repl.History)
+			repl.mutex.Unlock()
+			fmt.Print("\r\n")
+			if !repl.Execute(line) {
+				return nil
+			}
+			repl.Redraw()
+			continue
+		case 127, 8: // Backspace
+			if len(repl.Line) > 0 {
+				repl.Line = repl.Line[:len(repl.Line)-1]
+			}
+		case '\t':
+			repl.complete()
+		case 27: // Escape sequences: only the up and down arrows are supported
+			if next, _, _ := reader.ReadRune(); next == '[' {
+				switch arrow, _, _ := reader.ReadRune(); arrow {
+				case 'A':
+					historyIndex = max(0, historyIndex-1)
+				case 'B':
+					historyIndex = min(len(repl.History), historyIndex+1)
+				}
+				repl.Line = nil
+				if historyIndex < len(repl.History) {
+					repl.Line = []rune(repl.History[historyIndex])
+				}
+			}
+		default:
+			if key >= ' ' {
+				repl.Line = append(repl.Line, key)
+			}
+		}
+		repl.mutex.Unlock()
+		repl.Redraw()
+	}
+}
+
+// Execute handles a line entered by the user, returning false when the session should end
+func (repl *ReplSession) Execute(line string) bool {
+	cmd := ParseCommand(line)
+	switch cmd.Name {
+	case "":
+	case "quit", "exit":
+		return false
+	case "help":
+		repl.Println("commands: " + strings.Join(repl.candidates(""), " "))
+	default:
+		replies, err := Request(repl.Addr, repl.message(cmd.String()))
+		for _, reply := range replies {
+			repl.Println(reply)
+		}
+		if err != nil {
+			repl.Println("error: " + err.Error())
+		}
+	}
+	return true
+}
+
+// SetStatus updates the live status line with the latest state from the daemon
+func (repl *ReplSession) SetStatus(status string) {
+	repl.mutex.Lock()
+	repl.Status = status
+	repl.mutex.Unlock()
+	repl.Redraw()
+}
+
+// Println prints a message above the prompt
+func (repl *ReplSession) Println(message string) {
+	fmt.Print("\r\x1b[K" + message + "\r\n")
+	repl.Redraw()
+}
+
+// Redraw repaints the prompt line
+func (repl *ReplSession) Redraw() {
+	repl.mutex.Lock()
+	defer repl.mutex.Unlock()
+	fmt.Printf("\r\x1b[K[%s] pomo> %s", repl.Status, string(repl.Line))
+}
+
+// complete expands the command being typed, listing the candidates when ambiguous
+func (repl *ReplSession) complete() {
+	prefix := string(repl.Line)
+	if strings.Contains(prefix, " ") {
+		return
+	}
+	candidates := repl.candidates(prefix)
+	switch len(candidates) {
+	case 0:
+	case 1:
+		repl.Line = []rune(candidates[0] + " ")
+	default:
+		fmt.Print("\r\x1b[K" + strings.Join(candidates, " ") + "\r\n")
+	}
+}
+
+func (repl *ReplSession) candidates(prefix string) []string {
+	var candidates []string
+	for _, name := range append(append([]string{}, CommandNames...), ReplCommands...) {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+func (repl *ReplSession) message(command string) string {
+	if repl.Token != "" {
+		return repl.Token + " " + command
+	}
+	return command
+}
+
+// makeRaw puts the terminal in raw mode (no echo, no line buffering) and returns its previous state
+func makeRaw(fd int) (*syscall.Termios, error) {
+	var old syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&old))); errno != 0 {
+		return nil, errno
+	}
+
+	raw := old
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&raw))); errno != 0 {
+		return nil, errno
+	}
+	return &old, nil
+}
+
+// restoreTerminal restores a terminal state saved by makeRaw
+func restoreTerminal(fd int, state *syscall.Termios) {
+	syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(state)))
+}
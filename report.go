@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ChartWidth and ChartHeight are the size in pixels of each chart of a report
+const (
+	ChartWidth  = 640
+	ChartHeight = 200
+	chartMargin = 30 // Room for the title above a chart and the dates below it
+)
+
+// ChartColor is the colour of the bars and lines of the charts
+var ChartColor = color.RGBA{0xe7, 0x4c, 0x3c, 0xff}
+
+// Series counts the completed pomodoros per period, from the period starting on First
+type Series struct {
+	First  time.Time
+	Step   int // Days per period
+	Counts []int
+}
+
+// Report renders charts of the history to an SVG or PNG file: the completed
+// pomodoros per day and their weekly trend
+func Report(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	fileFlag := flags.String("file", HistoryPath(), "History file")
+	chartFlag := flags.String("chart", "", "Chart file to write, as SVG or PNG depending on its extension")
+	daysFlag := flags.Int("days", 30, "Number of days of the daily chart")
+	weeksFlag := flags.Int("weeks", 12, "Number of weeks of the weekly trend")
+	parseFilter := filterFlags(flags)
+	flags.Parse(args)
+
+	var render func(io.Writer, Series, Series) error
+	switch strings.ToLower(filepath.Ext(*chartFlag)) {
+	case ".svg":
+		render = ChartSVG
+	case ".png":
+		render = ChartPNG
+	default:
+		return errors.New("usage: polybar-pomo report -chart <file.svg|file.png>")
+	}
+	if *daysFlag < 1 || *weeksFlag < 1 {
+		return errors.New("-days and -weeks must be positive")
+	}
+	filter, err := parseFilter()
+	if err != nil {
+		return err
+	}
+
+	history := &History{Path: *fileFlag}
+	sessions, err := history.Load()
+	if err != nil {
+		return err
+	}
+	sessions = filter.Apply(sessions)
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	daily := CountPomodoros(sessions, today.AddDate(0, 0, 1-*daysFlag), 1, *daysFlag)
+	weekly := CountPomodoros(sessions, monday.AddDate(0, 0, -7*(*weeksFlag-1)), 7, *weeksFlag)
+
+	file, err := os.Create(*chartFlag)
+	if err != nil {
+		return err
+	}
+	if err := render(file, daily, weekly); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// CountPomodoros counts the completed work sessions in n periods of step days
+// starting at midnight of first
+func CountPomodoros(sessions []Session, first time.Time, step, n int) Series {
+	series := Series{First: first, Step: step, Counts: make([]int, n)}
+	for _, session := range sessions {
+		if session.Status != Work.String() || session.Result != Completed {
+			continue
+		}
+		start := session.Start.In(first.Location())
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, first.Location())
+		// Rounded as days around DST changes aren't 24 hours long
+		period := int(math.Round(day.Sub(first).Hours()/24)) / step
+		if !day.Before(first) && period < n {
+			series.Counts[period]++
+		}
+	}
+	return series
+}
+
+// Label returns the date of the start of the i-th period, e.g. "Jan 2"
+func (series Series) Label(i int) string {
+	return series.First.AddDate(0, 0, i*series.Step).Format("Jan 2")
+}
+
+// highest returns the largest count of the series, at least 1 to scale the charts
+func (series Series) highest() int {
+	highest := 1
+	for _, count := range series.Counts {
+		highest = max(highest, count)
+	}
+	return highest
+}
+
+// chartBars returns the bars of a series in the chart whose top is at y
+func chartBars(series Series, y int) []image.Rectangle {
+	width := float64(ChartWidth-2*chartMargin) / float64(len(series.Counts))
+	bottom := y + ChartHeight - chartMargin
+	height := float64(ChartHeight - 2*chartMargin)
+
+	bars := make([]image.Rectangle, len(series.Counts))
+	for i, count := range series.Counts {
+		left := chartMargin + int(float64(i)*width)
+		right := chartMargin + int(float64(i+1)*width) - max(1, int(width/5))
+		bars[i] = image.Rect(left, bottom-int(height*float64(count)/float64(series.highest())), right, bottom)
+	}
+	return bars
+}
+
+// chartPoints returns the points of a series in the chart whose top is at y,
+// centred in their period
+func chartPoints(series Series, y int) []image.Point {
+	points := make([]image.Point, len(series.Counts))
+	for i, bar := range chartBars(series, y) {
+		points[i] = image.Pt((bar.Min.X+bar.Max.X)/2, bar.Min.Y)
+	}
+	return points
+}
+
+// ChartSVG writes the daily chart as bars and the weekly trend as a line, one
+// below the other, as an SVG image
+func ChartSVG(w io.Writer, daily, weekly Series) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12" fill="#666">`+"\n", ChartWidth, 2*ChartHeight)
+	fill := fmt.Sprintf("#%02x%02x%02x", ChartColor.R, ChartColor.G, ChartColor.B)
+
+	axes := func(series Series, y int, title string) {
+		bottom := y + ChartHeight - chartMargin
+		fmt.Fprintf(&builder, `<text x="%d" y="%d" font-size="14" fill="#333">%s</text>`+"\n", chartMargin, y+chartMargin/2, svgEscape(title))
+		fmt.Fprintf(&builder, `<text x="%d" y="%d" text-anchor="end">%d</text>`+"\n", chartMargin-6, y+chartMargin+4, series.highest())
+		fmt.Fprintf(&builder, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#ccc"/>`+"\n", chartMargin, bottom, ChartWidth-chartMargin, bottom)
+		fmt.Fprintf(&builder, `<text x="%d" y="%d">%s</text>`+"\n", chartMargin, bottom+16, series.Label(0))
+		fmt.Fprintf(&builder, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", ChartWidth-chartMargin, bottom+16, series.Label(len(series.Counts)-1))
+	}
+
+	axes(daily, 0, T("Pomodoros per day"))
+	for i, bar := range chartBars(daily, 0) {
+		if daily.Counts[i] > 0 {
+			fmt.Fprintf(&builder, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"><title>%s: %d</title></rect>`+"\n",
+				bar.Min.X, bar.Min.Y, bar.Dx(), bar.Dy(), fill, daily.Label(i), daily.Counts[i])
+		}
+	}
+
+	axes(weekly, ChartHeight, T("Pomodoros per week"))
+	points := chartPoints(weekly, ChartHeight)
+	coordinates := make([]string, len(points))
+	for i, point := range points {
+		coordinates[i] = fmt.Sprintf("%d,%d", point.X, point.Y)
+	}
+	fmt.Fprintf(&builder, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(coordinates, " "), fill)
+	for i, point := range points {
+		fmt.Fprintf(&builder, `<circle cx="%d" cy="%d" r="3" fill="%s"><title>%s: %d</title></circle>`+"\n",
+			point.X, point.Y, fill, weekly.Label(i), weekly.Counts[i])
+	}
+	builder.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// svgEscape escapes the text of an SVG element
+func svgEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// ChartPNG writes the same charts as ChartSVG as a PNG image. As the standard
+// library can't draw text, it has no titles or labels.
+func ChartPNG(w io.Writer, daily, weekly Series) error {
+	img := image.NewRGBA(image.Rect(0, 0, ChartWidth, 2*ChartHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	axis := image.NewUniform(color.Gray{0xcc})
+	fill := image.NewUniform(ChartColor)
+
+	for _, y := range []int{0, ChartHeight} {
+		bottom := y + ChartHeight - chartMargin
+		draw.Draw(img, image.Rect(chartMargin, bottom, ChartWidth-chartMargin, bottom+1), axis, image.Point{}, draw.Src)
+	}
+	for _, bar := range chartBars(daily, 0) {
+		draw.Draw(img, bar, fill, image.Point{}, draw.Src)
+	}
+
+	points := chartPoints(weekly, ChartHeight)
+	for i, point := range points {
+		draw.Draw(img, image.Rect(point.X-3, point.Y-3, point.X+4, point.Y+4), fill, image.Point{}, draw.Src)
+		if i > 0 {
+			drawLine(img, points[i-1], point, ChartColor)
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// drawLine draws a line two pixels thick between two points
+func drawLine(img *image.RGBA, from, to image.Point, c color.Color) {
+	steps := max(abs(to.X-from.X), abs(to.Y-from.Y), 1)
+	for step := 0; step <= steps; step++ {
+		x := from.X + (to.X-from.X)*step/steps
+		y := from.Y + (to.Y-from.Y)*step/steps
+		img.Set(x, y, c)
+		img.Set(x, y+1, c)
+	}
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
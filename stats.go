@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary aggregates the sessions of the history
+type Summary struct {
+	Completed int // Completed work sessions
+	Abandoned int // Abandoned work sessions
+	Aborted   int // Work sessions interrupted by a crash
+	Rests     int // Rest sessions of any result
+
+	Focus time.Duration // Time spent running in work sessions
+
+	Interruptions map[string]int          // Number of interruptions by reason
+	Tasks         map[string]*TaskSummary // Estimated and actual pomodoros by task label
+	Notes         []SessionNote           // Notes of the sessions, oldest first
+}
+
+// SessionNote is a note taken about a session
+type SessionNote struct {
+	Start time.Time `json:"start"`          // Start of the session
+	Task  string    `json:"task,omitempty"` // Task of the session, if any
+	Text  string    `json:"text"`
+}
+
+// Label returns the text of the note, prefixed with its task if any
+func (note SessionNote) Label() string {
+	if note.Task == "" {
+		return note.Text
+	}
+	return note.Task + ": " + note.Text
+}
+
+// TaskSummary compares the estimated and actual pomodoros of a task
+type TaskSummary struct {
+	Estimate int `json:"estimate"` // Latest estimate of the task, 0 when never estimated
+	Actual   int `json:"actual"`   // Completed work sessions
+}
+
+// Summarize aggregates the sessions
+func Summarize(sessions []Session) Summary {
+	summary := Summary{Interruptions: make(map[string]int), Tasks: make(map[string]*TaskSummary)}
+	for _, session := range sessions {
+		for _, text := range session.Notes {
+			summary.Notes = append(summary.Notes, SessionNote{session.Start, session.Task, text})
+		}
+		if session.Status != Work.String() {
+			summary.Rests++
+			continue
+		}
+
+		switch session.Result {
+		case Completed:
+			summary.Completed++
+		case Abandoned:
+			summary.Abandoned++
+		case Aborted:
+			summary.Aborted++
+		}
+		summary.Focus += time.Duration(session.Elapsed)
+		for _, reason := range session.Interruptions {
+			summary.Interruptions[reason]++
+		}
+
+		if session.Task != "" {
+			task := summary.Tasks[session.Task]
+			if task == nil {
+				task = &TaskSummary{}
+				summary.Tasks[session.Task] = task
+			}
+			if session.Estimate > 0 {
+				task.Estimate = session.Estimate
+			}
+			if session.Result == Completed {
+				task.Actual++
+			}
+		}
+	}
+	return summary
+}
+
+// TopInterruptions returns the n most common interruption reasons, most common first
+func (summary Summary) TopInterruptions(n int) []string {
+	reasons := make([]string, 0, len(summary.Interruptions))
+	for reason := range summary.Interruptions {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		a, b := summary.Interruptions[reasons[i]], summary.Interruptions[reasons[j]]
+		return a > b || (a == b && reasons[i] < reasons[j])
+	})
+	return reasons[:min(n, len(reasons))]
+}
+
+// SessionFilter selects the sessions of the history to aggregate, its zero
+// fields matching every session
+type SessionFilter struct {
+	Tag  string    // Task label of the sessions
+	From time.Time // Sessions must start at or after it
+	To   time.Time // Sessions must start before it
+}
+
+// Match returns whether the session passes every filter
+func (filter SessionFilter) Match(session Session) bool {
+	return (filter.Tag == "" || session.Task == filter.Tag) &&
+		(filter.From.IsZero() || !session.Start.Before(filter.From)) &&
+		(filter.To.IsZero() || session.Start.Before(filter.To))
+}
+
+// Apply returns the sessions matching the filter
+func (filter SessionFilter) Apply(sessions []Session) []Session {
+	var matching []Session
+	for _, session := range sessions {
+		if filter.Match(session) {
+			matching = append(matching, session)
+		}
+	}
+	return matching
+}
+
+// filterFlags defines the -tag, -from and -to flags of the session filter,
+// returning a function building the filter once the flags are parsed
+func filterFlags(flags *flag.FlagSet) func() (SessionFilter, error) {
+	tagFlag := flags.String("tag", "", "Only count the sessions of this task")
+	fromFlag := flags.String("from", "", "Only count the sessions started on or after this date, as YYYY-MM-DD")
+	toFlag := flags.String("to", "", "Only count the sessions started before this date, as YYYY-MM-DD")
+
+	return func() (SessionFilter, error) {
+		filter := SessionFilter{Tag: *tagFlag}
+		var err error
+		if filter.From, err = parseDate(*fromFlag); err != nil {
+			return filter, fmt.Errorf("invalid -from: %w", err)
+		}
+		if filter.To, err = parseDate(*toFlag); err != nil {
+			return filter, fmt.Errorf("invalid -to: %w", err)
+		}
+		return filter, nil
+	}
+}
+
+// parseDate parses a YYYY-MM-DD date at midnight local time, the zero time when empty
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
+
+// Stats prints a summary of the history
+func Stats(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	fileFlag := flags.String("file", HistoryPath(), "History file")
+	topFlag := flags.Int("top", 5, "Number of interruption reasons to show")
+	notesFlag := flags.Int("notes", 5, "Number of latest notes to show")
+	heatmapFlag := flags.Bool("heatmap", false, "Show a per-day heatmap of completed pomodoros instead")
+	weeksFlag := flags.Int("weeks", 12, "Number of weeks of the heatmap")
+	hoursFlag := flags.Bool("hours", false, "Show the focus time by hour of the day instead")
+	jsonFlag := flags.Bool("json", false, "Print the summary and the focus time by hour of the day as JSON")
+	achievementsFlag := flags.Bool("achievements", false, "List the achievements and the progress towards them instead")
+	parseFilter := filterFlags(flags)
+	flags.Parse(args)
+
+	filter, err := parseFilter()
+	if err != nil {
+		return err
+	}
+	if *heatmapFlag && *weeksFlag <= 0 {
+		return fmt.Errorf("invalid number of weeks %d (expected at least 1)", *weeksFlag)
+	}
+	history := &History{Path: *fileFlag}
+	sessions, err := history.Load()
+	if err != nil {
+		return err
+	}
+	sessions = filter.Apply(sessions)
+	if *heatmapFlag {
+		return Heatmap(os.Stdout, sessions, *weeksFlag, time.Now())
+	} else if *achievementsFlag {
+		return ListAchievements(os.Stdout, MeasureProgress(sessions))
+	} else if *jsonFlag {
+		return StatsJSON(os.Stdout, Summarize(sessions), HourlyFocus(sessions))
+	} else if *hoursFlag {
+		return HoursChart(os.Stdout, HourlyFocus(sessions))
+	}
+	summary := Summarize(sessions)
+
+	interruptions := 0
+	for _, count := range summary.Interruptions {
+		interruptions += count
+	}
+
+	fmt.Printf("%-15s %s\n", T("Work sessions:"), T("%d completed, %d abandoned, %d aborted", summary.Completed, summary.Abandoned, summary.Aborted))
+	fmt.Printf("%-15s %s\n", T("Focus time:"), summary.Focus.Round(time.Minute))
+	fmt.Printf("%-15s %d\n", T("Rest sessions:"), summary.Rests)
+	fmt.Printf("%-15s %d\n", T("Interruptions:"), interruptions)
+	for _, reason := range summary.TopInterruptions(*topFlag) {
+		label := reason
+		if label == "" {
+			label = T("(no reason)")
+		}
+		fmt.Printf("  %4d  %s\n", summary.Interruptions[reason], label)
+	}
+
+	if len(summary.Tasks) > 0 {
+		fmt.Printf("%-15s %8s  %6s\n", T("Tasks:"), T("estimate"), T("actual"))
+		labels := make([]string, 0, len(summary.Tasks))
+		for label := range summary.Tasks {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			task := summary.Tasks[label]
+			estimate, difference := "-", ""
+			if task.Estimate > 0 {
+				estimate = fmt.Sprint(task.Estimate)
+				difference = fmt.Sprintf("  (%+d)", task.Actual-task.Estimate)
+			}
+			fmt.Printf("  %-12s  %8s  %6d%s\n", label, estimate, task.Actual, difference)
+		}
+	}
+
+	if notes := summary.Notes[len(summary.Notes)-min(max(0, *notesFlag), len(summary.Notes)):]; len(notes) > 0 {
+		fmt.Println(T("Notes:"))
+		for _, note := range notes {
+			fmt.Printf("  %s  %s\n", note.Start.Local().Format("2006-01-02 15:04"), note.Label())
+		}
+	}
+	return nil
+}
+
+// HeatmapColors are the ANSI 256 colours of the heatmap cells, from no pomodoro to the busiest days
+var HeatmapColors = []int{238, 22, 28, 34, 40}
+
+// Heatmap writes a GitHub-style heatmap of the completed pomodoros per day of
+// the last weeks: a row per weekday and a column per week, ending with today
+func Heatmap(w io.Writer, sessions []Session, weeks int, now time.Time) error {
+	days := make(map[string]int)
+	busiest := 0
+	for _, session := range sessions {
+		if session.Status == Work.String() && session.Result == Completed {
+			day := session.Start.Local().Format(time.DateOnly)
+			days[day]++
+			busiest = max(busiest, days[day])
+		}
+	}
+
+	// Columns start on Mondays
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	first := monday.AddDate(0, 0, -7*(weeks-1))
+
+	// Month labels above the columns where a month starts
+	header := []rune(strings.Repeat(" ", 2*weeks+4))
+	for week := 0; week < weeks; week++ {
+		start := first.AddDate(0, 0, 7*week)
+		if week == 0 || start.AddDate(0, 0, -7).Month() != start.Month() {
+			label := []rune(start.Format("Jan"))
+			if 4+2*week+len(label) <= len(header) {
+				copy(header[4+2*week:], label)
+			}
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString(strings.TrimRight(string(header), " ") + "\n")
+	for weekday := 0; weekday < 7; weekday++ {
+		builder.WriteString(first.AddDate(0, 0, weekday).Format("Mon")[:2] + "  ")
+		for week := 0; week < weeks; week++ {
+			day := first.AddDate(0, 0, 7*week+weekday)
+			if day.After(today) {
+				break
+			}
+			level := 0
+			if count := days[day.Format(time.DateOnly)]; count > 0 {
+				level = 1 + (count-1)*(len(HeatmapColors)-1)/busiest
+			}
+			fmt.Fprintf(&builder, "\x1b[38;5;%dm■\x1b[0m ", HeatmapColors[level])
+		}
+		builder.WriteString("\n")
+	}
+	fmt.Fprintf(&builder, "\n%s\n", T("Most pomodoros in a day: %d", busiest))
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// HourlyFocus returns the focus time of the completed work sessions by hour of
+// the day, each session being split across the hours it spans
+func HourlyFocus(sessions []Session) [24]time.Duration {
+	var hours [24]time.Duration
+	for _, session := range sessions {
+		span := session.End.Sub(session.Start)
+		if session.Status != Work.String() || session.Result != Completed || span <= 0 {
+			continue
+		}
+		// Pauses are spread over the session, as the history doesn't say when they happened
+		ratio := float64(session.Elapsed) / float64(span)
+		end := session.End.Local()
+		for start := session.Start.Local(); start.Before(end); {
+			next := time.Date(start.Year(), start.Month(), start.Day(), start.Hour()+1, 0, 0, 0, start.Location())
+			if next.After(end) {
+				next = end
+			}
+			hours[start.Hour()] += time.Duration(float64(next.Sub(start)) * ratio)
+			start = next
+		}
+	}
+	return hours
+}
+
+// BestHours returns the first of the two consecutive hours with the most focus time
+func BestHours(hours [24]time.Duration) int {
+	best := 0
+	for hour := 1; hour < 23; hour++ {
+		focus, bestFocus := hours[hour]+hours[hour+1], hours[best]+hours[best+1]
+		if focus > bestFocus || (focus == bestFocus && hours[hour] > hours[best]) {
+			best = hour
+		}
+	}
+	return best
+}
+
+// HoursChart writes a bar chart of the focus time by hour of the day, from
+// the first to the last hour with focus time
+func HoursChart(w io.Writer, hours [24]time.Duration) error {
+	first, last, longest := -1, -1, time.Duration(0)
+	for hour, focus := range hours {
+		if focus > 0 {
+			if first < 0 {
+				first = hour
+			}
+			last = hour
+			longest = max(longest, focus)
+		}
+	}
+	if first < 0 {
+		_, err := fmt.Fprintln(w, T("No completed pomodoros yet"))
+		return err
+	}
+
+	const width = 40
+	var builder strings.Builder
+	for hour := first; hour <= last; hour++ {
+		bar := strings.Repeat("█", int(int64(hours[hour])*width/int64(longest)))
+		fmt.Fprintf(&builder, "%02d:00  %-*s %s\n", hour, width, bar, hours[hour].Round(time.Minute))
+	}
+	best := BestHours(hours)
+	fmt.Fprintf(&builder, "\n%s\n", T("You focus best from %02d:00 to %02d:00", best, best+2))
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// StatsJSON writes the summary as a JSON object, along with the focus time by
+// hour of the day as an array of {"hour", "focus"} objects. Times are in seconds.
+func StatsJSON(w io.Writer, summary Summary, hours [24]time.Duration) error {
+	type hourFocus struct {
+		Hour  int     `json:"hour"`
+		Focus Seconds `json:"focus"`
+	}
+	distribution := make([]hourFocus, 0, len(hours))
+	for hour, focus := range hours {
+		distribution = append(distribution, hourFocus{hour, Seconds(focus)})
+	}
+	return json.NewEncoder(w).Encode(struct {
+		Completed     int                     `json:"completed"`
+		Abandoned     int                     `json:"abandoned"`
+		Aborted       int                     `json:"aborted"`
+		Rests         int                     `json:"rests"`
+		Focus         Seconds                 `json:"focus"`
+		Interruptions map[string]int          `json:"interruptions"`
+		Tasks         map[string]*TaskSummary `json:"tasks"`
+		Notes         []SessionNote           `json:"notes,omitempty"`
+		Hours         []hourFocus             `json:"hours"`
+	}{
+		summary.Completed, summary.Abandoned, summary.Aborted, summary.Rests, Seconds(summary.Focus),
+		summary.Interruptions, summary.Tasks, summary.Notes, distribution,
+	})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeatmap(t *testing.T) {
+	cell := func(level int) string { return fmt.Sprintf("\x1b[38;5;%dm■\x1b[0m ", HeatmapColors[level]) }
+	day := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.Local)
+	}
+	sessions := []Session{
+		{Start: day(time.February, 27, 9), Status: Work.String(), Result: Completed},
+		{Start: day(time.March, 4, 9), Status: Work.String(), Result: Abandoned},
+		{Start: day(time.March, 4, 10), Status: Rest.String(), Result: Completed},
+		{Start: day(time.March, 6, 9), Status: Work.String(), Result: Completed},
+		{Start: day(time.March, 6, 10), Status: Work.String(), Result: Completed},
+	}
+	now := day(time.March, 6, 18) // A Wednesday
+
+	tests := []struct {
+		name     string
+		sessions []Session
+		weeks    int
+		want     []string
+	}{
+		{"two weeks", sessions, 2, []string{
+			"    Feb",
+			"Mo  " + cell(0) + cell(0),
+			"Tu  " + cell(1) + cell(0),
+			"We  " + cell(0) + cell(3),
+			"Th  " + cell(0),
+			"Fr  " + cell(0),
+			"Sa  " + cell(0),
+			"Su  " + cell(0),
+			"",
+			"Most pomodoros in a day: 2",
+		}},
+		{"one week", sessions, 1, []string{
+			"",
+			"Mo  " + cell(0),
+			"Tu  " + cell(0),
+			"We  " + cell(3),
+			"Th  ",
+			"Fr  ",
+			"Sa  ",
+			"Su  ",
+			"",
+			"Most pomodoros in a day: 2",
+		}},
+		{"no sessions", nil, 1, []string{
+			"",
+			"Mo  " + cell(0),
+			"Tu  " + cell(0),
+			"We  " + cell(0),
+			"Th  ",
+			"Fr  ",
+			"Sa  ",
+			"Su  ",
+			"",
+			"Most pomodoros in a day: 0",
+		}},
+	}
+	for _, test := range tests {
+		var builder strings.Builder
+		if err := Heatmap(&builder, test.sessions, test.weeks, now); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got, want := builder.String(), strings.Join(test.want, "\n")+"\n"; got != want {
+			t.Errorf("%s: Heatmap =\n%q\nwant\n%q", test.name, got, want)
+		}
+	}
+}
+
+func TestHourlyFocus(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, time.March, 6, hour, minute, 0, 0, time.Local)
+	}
+	work := func(start, end time.Time, elapsed time.Duration) Session {
+		return Session{Start: start, End: end, Status: Work.String(), Elapsed: Seconds(elapsed), Result: Completed}
+	}
+	tests := []struct {
+		name     string
+		sessions []Session
+		want     map[int]time.Duration
+	}{
+		{"within an hour", []Session{work(at(9, 0), at(9, 25), 25*time.Minute)}, map[int]time.Duration{9: 25 * time.Minute}},
+		{"across hours", []Session{work(at(9, 50), at(10, 15), 25*time.Minute)}, map[int]time.Duration{9: 10 * time.Minute, 10: 15 * time.Minute}},
+		{"pauses spread", []Session{work(at(9, 40), at(10, 20), 20*time.Minute)}, map[int]time.Duration{9: 10 * time.Minute, 10: 10 * time.Minute}},
+		{"across midnight", []Session{work(at(23, 50), at(24, 10), 20*time.Minute)}, map[int]time.Duration{23: 10 * time.Minute, 0: 10 * time.Minute}},
+		{"summed", []Session{work(at(9, 0), at(9, 25), 25*time.Minute), work(at(9, 30), at(9, 55), 25*time.Minute)}, map[int]time.Duration{9: 50 * time.Minute}},
+		{"ignored", []Session{
+			{Start: at(9, 0), End: at(9, 25), Status: Work.String(), Elapsed: Seconds(25 * time.Minute), Result: Abandoned},
+			{Start: at(9, 0), End: at(9, 5), Status: Rest.String(), Elapsed: Seconds(5 * time.Minute), Result: Completed},
+			work(at(9, 0), at(9, 0), 0),
+		}, nil},
+	}
+	for _, test := range tests {
+		var want [24]time.Duration
+		for hour, focus := range test.want {
+			want[hour] = focus
+		}
+		if got := HourlyFocus(test.sessions); got != want {
+			t.Errorf("%s: HourlyFocus = %v, want %v", test.name, got, want)
+		}
+	}
+}
+
+func TestStatsHeatmapWeeks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, weeks := range []string{"0", "-1", "-3"} {
+		if err := Stats([]string{"-file", path, "-heatmap", "-weeks", weeks}); err == nil {
+			t.Errorf("Stats -weeks %s succeeded", weeks)
+		}
+	}
+}
+
+func TestStatsJSON(t *testing.T) {
+	var hours [24]time.Duration
+	hours[9], hours[10] = 25*time.Minute, 5*time.Minute
+	zeros := func(from, to int) string {
+		var parts []string
+		for hour := from; hour <= to; hour++ {
+			parts = append(parts, fmt.Sprintf(`{"hour":%d,"focus":0}`, hour))
+		}
+		return strings.Join(parts, ",")
+	}
+	tests := []struct {
+		name    string
+		summary Summary
+		hours   [24]time.Duration
+		want    string
+	}{
+		{"empty", Summarize(nil), [24]time.Duration{},
+			`{"completed":0,"abandoned":0,"aborted":0,"rests":0,"focus":0,"interruptions":{},"tasks":{},"hours":[` + zeros(0, 23) + `]}`},
+		{"sessions", Summary{
+			Completed: 1, Abandoned: 1, Rests: 2, Focus: 30 * time.Minute,
+			Interruptions: map[string]int{"slack": 1}, Tasks: map[string]*TaskSummary{"docs": {Estimate: 2, Actual: 1}},
+		}, hours,
+			`{"completed":1,"abandoned":1,"aborted":0,"rests":2,"focus":1800,"interruptions":{"slack":1},"tasks":{"docs":{"estimate":2,"actual":1}},"hours":[` +
+				zeros(0, 8) + `,{"hour":9,"focus":1500},{"hour":10,"focus":300},` + zeros(11, 23) + `]}`},
+		{"notes", Summarize([]Session{
+			{Start: time.Date(2024, time.March, 6, 9, 0, 0, 0, time.UTC), Status: Work.String(), Result: Completed, Task: "docs", Notes: []string{"outline", "intro"}},
+			{Start: time.Date(2024, time.March, 6, 9, 25, 0, 0, time.UTC), Status: Rest.String(), Result: Completed, Notes: []string{"coffee"}},
+		}), [24]time.Duration{},
+			`{"completed":1,"abandoned":0,"aborted":0,"rests":1,"focus":0,"interruptions":{},"tasks":{"docs":{"estimate":0,"actual":1}},"notes":[` +
+				`{"start":"2024-03-06T09:00:00Z","task":"docs","text":"outline"},{"start":"2024-03-06T09:00:00Z","task":"docs","text":"intro"},` +
+				`{"start":"2024-03-06T09:25:00Z","text":"coffee"}],"hours":[` + zeros(0, 23) + `]}`},
+	}
+	for _, test := range tests {
+		var builder strings.Builder
+		if err := StatsJSON(&builder, test.summary, test.hours); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := strings.TrimSpace(builder.String()); got != test.want {
+			t.Errorf("%s: StatsJSON =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
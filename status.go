@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// StatusStale is how old the status files can be before the daemon is
+// considered gone. The daemon touches them every StatusStale/2, even when
+// the state doesn't change.
+const StatusStale = time.Minute
+
+// StatusFileNames are the files mirroring the state, by output format
+var StatusFileNames = map[string]string{"polybar": "status", "json": "status.json", "conky": "status.conky"}
+
+// StatusFiles mirrors every update to files, so tools that can only read
+// files (conky, scripts, shell prompts) can consume the state
+type StatusFiles struct {
+	Dir string
+}
+
+// StatusDir returns the default directory of the status files, under XDG_RUNTIME_DIR when set
+func StatusDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "polybar-pomo")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("polybar-pomo-%d", os.Getuid()))
+}
+
+// MakeStatusDir creates the directory of the status files, socket and PID
+// file, refusing to use it unless it is private to the user: in the shared
+// /tmp fallback, another user could have created it first
+func MakeStatusDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is owned by another user", dir)
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	} else if info.Mode().Perm() != 0o700 {
+		return fmt.Errorf("%s has mode %s (expected 0700)", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// DefaultSocketPath returns the path of the Unix socket, in the runtime directory
+func DefaultSocketPath() string {
+	return filepath.Join(StatusDir(), "socket")
+}
+
+// LinkLegacySocket links the socket path of older versions to the socket, so
+// the bar configs and scripts using it keep working. It replaces a link or a
+// stale socket left by the user, but not a file of another user.
+func LinkLegacySocket() error {
+	info, err := os.Lstat(LegacySocketPath)
+	if err == nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != os.Getuid() {
+			return errors.New("owned by another user")
+		} else if info.Mode()&(os.ModeSymlink|os.ModeSocket) == 0 {
+			return errors.New("not a socket")
+		}
+		if err := os.Remove(LegacySocketPath); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Symlink(SocketPath, LegacySocketPath)
+}
+
+// Name returns the name of the output
+func (files StatusFiles) Name() string {
+	return "status files"
+}
+
+// Refresh writes the status files
+func (files StatusFiles) Refresh(state *PomodoroState) error {
+	return files.Write(state)
+}
+
+// Write renders the state into the "status" (polybar), "status.json" (json)
+// and "status.conky" (conky) files, and the "prompt" file read by the prompt
+// subcommand
+func (files StatusFiles) Write(state *PomodoroState) error {
+	if err := MakeStatusDir(files.Dir); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(files.Dir, "status"), state.String()); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(files.Dir, "status.json"), RenderJSON(state)); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(files.Dir, "status.conky"), RenderConky(state)); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(files.Dir, "prompt"), PromptLine(state))
+}
+
+// names returns the names of every file written by Write
+func (files StatusFiles) names() []string {
+	names := []string{"prompt"}
+	for _, name := range StatusFileNames {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Touch updates the modification time of the status and prompt files, to show the daemon is still running
+func (files StatusFiles) Touch() error {
+	now := time.Now()
+	for _, name := range files.names() {
+		if err := os.Chtimes(filepath.Join(files.Dir, name), now, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove deletes the status and prompt files, so they don't show a timer that no longer runs
+func (files StatusFiles) Remove() error {
+	var errs []error
+	for _, name := range files.names() {
+		if err := os.Remove(filepath.Join(files.Dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeFileAtomic replaces the file content through a rename, so readers never see a partial line
+func writeFileAtomic(path, line string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(line+"\n"), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMakeStatusDir(t *testing.T) {
+	root := t.TempDir()
+	shared := filepath.Join(root, "shared")
+	if err := os.Mkdir(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	private := filepath.Join(root, "private")
+	if err := os.Mkdir(private, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(private, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir string
+		err bool
+	}{
+		{filepath.Join(root, "new"), false},
+		{private, false},
+		{shared, true},
+		{link, true},
+	}
+	for _, test := range tests {
+		if err := MakeStatusDir(test.dir); (err != nil) != test.err {
+			t.Errorf("MakeStatusDir(%s) error = %v, want error %t", filepath.Base(test.dir), err, test.err)
+		}
+	}
+}
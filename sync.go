@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PeerTimeout bounds how long a sync message may take to reach a peer
+const PeerTimeout = 2 * time.Second
+
+// PeerQueue is the number of messages waiting for a peer before new ones are dropped
+const PeerQueue = 64
+
+// Peers is a set of remote daemons kept in sync with the local state
+type Peers struct {
+	Addrs []string
+	Token string
+
+	once   sync.Once
+	queues []chan string // One per address, delivered in order
+}
+
+// ParsePeers builds a Peers set from a comma-separated list of addresses
+func ParsePeers(list, token string) *Peers {
+	peers := &Peers{Token: token}
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			peers.Addrs = append(peers.Addrs, addr)
+		}
+	}
+	return peers
+}
+
+// Send delivers the message to every peer in the background, in the order
+// the messages are sent, so that a peer never ends on a stale snapshot
+func (peers *Peers) Send(message string) {
+	peers.once.Do(peers.start)
+	if peers.Token != "" {
+		message = peers.Token + " " + message
+	}
+	for i, queue := range peers.queues {
+		select {
+		case queue <- message:
+		default:
+			slog.Warn("syncing peer", "addr", peers.Addrs[i], "err", "queue full, dropping message")
+		}
+	}
+}
+
+// start runs one delivery goroutine per peer
+func (peers *Peers) start() {
+	peers.queues = make([]chan string, len(peers.Addrs))
+	for i, addr := range peers.Addrs {
+		peers.queues[i] = make(chan string, PeerQueue)
+		go func(addr string, queue chan string) {
+			for message := range queue {
+				if err := Send(addr, message); err != nil {
+					slog.Warn("syncing peer", "addr", addr, "err", err)
+				}
+			}
+		}(addr, peers.queues[i])
+	}
+}
+
+// PeerCommands are the commands sent by peers, applied locally without being
+// synced back or forwarded to a leader
+var PeerCommands = map[string]bool{"sync": true, "merge": true}
+
+// SendSession delivers a recorded session to every peer, for them to merge
+// it into their history
+func (peers *Peers) SendSession(session Session) {
+	if len(peers.Addrs) == 0 {
+		return
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		slog.Warn("encoding session for peers", "err", err)
+		return
+	}
+	// Encoded, as commands are split on whitespace
+	peers.Send("merge " + base64.StdEncoding.EncodeToString(data))
+}
+
+// Dial connects to the daemon listening on an address of the form scheme://address
+func Dial(addr string) (net.Conn, error) {
+	scheme, address, found := strings.Cut(addr, "://")
+	if !found {
+		return nil, fmt.Errorf("invalid address %q (expected tcp://host:port, tls://host:port or unix://path)", addr)
+	}
+	if scheme == "tls" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		config, err := TLS.ClientConfig(host)
+		if err != nil {
+			return nil, err
+		}
+		return tls.DialWithDialer(&net.Dialer{Timeout: PeerTimeout}, "tcp", address, config)
+	}
+	conn, err := net.DialTimeout(scheme, address, PeerTimeout)
+	if err != nil && scheme == "unix" && address == DefaultSocketPath() {
+		// Daemons of older versions listen on the legacy path
+		if legacy, legacyErr := net.DialTimeout("unix", LegacySocketPath, PeerTimeout); legacyErr == nil {
+			return legacy, nil
+		}
+	}
+	return conn, err
+}
+
+// Send writes a single message to the daemon listening on addr
+func Send(addr, message string) error {
+	conn, err := Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(PeerTimeout))
+	_, err = conn.Write([]byte(message + "\n"))
+	return err
+}
+
+// Snapshot returns a sync command describing the current state. The remaining
+// time is sent instead of the end time so that clock skew between machines
+// doesn't matter.
+func (state *PomodoroState) Snapshot() string {
+	remaining := state.Remaining().Milliseconds()
+	return fmt.Sprintf("sync %d %t %d", state.Status, state.Paused, remaining)
+}
+
+// Sync replaces the current state with a snapshot received from a peer
+func (state *PomodoroState) Sync(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("invalid sync message %q", strings.Join(args, " "))
+	}
+
+	status, err := strconv.Atoi(args[0])
+	if err != nil || (PomodoroStatus(status) != Work && PomodoroStatus(status) != Rest) {
+		return fmt.Errorf("invalid sync status %q", args[0])
+	}
+	paused, err := strconv.ParseBool(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid sync paused flag %q", args[1])
+	}
+	remainingMs, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sync remaining time %q", args[2])
+	}
+	remaining := time.Duration(remainingMs) * time.Millisecond
+
+	state.Set(PomodoroStatus(status), paused, remaining)
+	return nil
+}
+
+// Merge adds a session recorded by a peer to the history
+func (state *PomodoroState) Merge(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("invalid merge message %q", strings.Join(args, " "))
+	}
+	data, err := base64.StdEncoding.DecodeString(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid merge message: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return fmt.Errorf("invalid merged session: %w", err)
+	}
+	if state.History == nil {
+		return nil
+	}
+
+	added, err := state.History.Merge(session)
+	if err != nil || !added {
+		return err
+	}
+	slog.Info("merged session from peer", "start", session.Start, "status", session.Status)
+	if today := time.Now().Format(time.DateOnly); session.Status == Work.String() && session.Result == Completed &&
+		session.Start.Local().Format(time.DateOnly) == today {
+		state.Today, state.TodayDate = state.CompletedToday()+1, today
+	}
+	return nil
+}
+
+// Merge adds a session to the history unless it holds the same period
+// already. Synced daemons both record the periods they share, with start
+// times apart by the sync delay, so a session of the same status overlapping
+// it counts as the same, besides the ones with the same start time.
+func (history *History) Merge(session Session) (bool, error) {
+	sessions, err := history.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	for _, recorded := range sessions {
+		if recorded.Status == session.Status && recorded.Start.Before(session.End) && session.Start.Before(recorded.End) {
+			return false, nil
+		}
+	}
+	merged, added := MergeSessions(sessions, []Session{session})
+	if added == 0 {
+		return false, nil
+	}
+	return true, history.Rewrite(merged)
+}
+
+// FollowRetry is the delay before reconnecting to a leader after losing it
+const FollowRetry = 5 * time.Second
+
+// Leader is the authoritative daemon whose timer a follower mirrors
+type Leader struct {
+	Addr    string
+	Token   string
+	Control bool // Whether local commands are forwarded to the leader or ignored
+
+	peers *Peers // Forwarded commands, delivered in order
+}
+
+// Follow joins the leader and forwards every snapshot it streams to the main
+// loop, reconnecting whenever the connection is lost
+func (leader *Leader) Follow(commands chan Command) {
+	for {
+		if err := leader.follow(commands); err != nil {
+			slog.Warn("following leader", "addr", leader.Addr, "err", err)
+		}
+		time.Sleep(FollowRetry)
+	}
+}
+
+func (leader *Leader) follow(commands chan Command) error {
+	conn, err := Dial(leader.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	message := "join"
+	if leader.Token != "" {
+		message = leader.Token + " " + message
+	}
+	if _, err := conn.Write([]byte(message + "\n")); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if cmd := ParseCommand(scanner.Text()); cmd.Name == "sync" {
+			commands <- cmd
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection to %s closed", leader.Addr)
+}
+
+// Forward relays a local command to the leader, or drops it when the follower is read-only
+func (leader *Leader) Forward(cmd Command) {
+	if !leader.Control {
+		slog.Info("ignoring command in read-only team mode", "command", cmd.Name)
+		return
+	}
+	if leader.peers == nil {
+		leader.peers = &Peers{Addrs: []string{leader.Addr}, Token: leader.Token}
+	}
+	leader.peers.Send(cmd.String())
+}
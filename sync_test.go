@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistoryMerge(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2024, time.March, 6, 9, minute, 0, 0, time.UTC) }
+	session := func(start, end int, status string) Session {
+		return Session{Start: at(start), End: at(end), Status: status, Result: Completed}
+	}
+	recorded := []Session{session(0, 25, Work.String()), session(25, 30, Rest.String())}
+
+	tests := []struct {
+		name    string
+		session Session
+		added   bool
+	}{
+		{"same period", session(0, 25, Work.String()), false},
+		{"recorded by a synced peer", Session{Start: at(0).Add(800 * time.Millisecond), End: at(25).Add(time.Second), Status: Work.String()}, false},
+		{"overlapping", session(1, 26, Work.String()), false},
+		{"other status", session(20, 25, Rest.String()), true},
+		{"later period", session(30, 55, Work.String()), true},
+		{"earlier period", session(-30, -5, Work.String()), true},
+	}
+	for _, test := range tests {
+		history := &History{Path: filepath.Join(t.TempDir(), "history.jsonl")}
+		if err := history.Rewrite(recorded); err != nil {
+			t.Fatal(err)
+		}
+		added, err := history.Merge(test.session)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if added != test.added {
+			t.Errorf("%s: Merge = %t, want %t", test.name, added, test.added)
+		}
+		sessions, err := history.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := recorded
+		if test.added {
+			want, _ = MergeSessions(append([]Session(nil), recorded...), []Session{test.session})
+		}
+		if !reflect.DeepEqual(sessions, want) {
+			t.Errorf("%s: history = %v, want %v", test.name, sessions, want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TelegramAPI is the base URL of the Telegram Bot API
+const TelegramAPI = "https://api.telegram.org/bot"
+
+// TelegramPollTimeout is how long a getUpdates request waits for new messages
+const TelegramPollTimeout = 30 * time.Second
+
+// TelegramCommands maps the bot commands to the daemon commands they send
+var TelegramCommands = map[string]string{
+	"/pause": "pause",
+	"/skip":  "toggle",
+}
+
+// Telegram pushes the state changes to a chat and accepts the commands of
+// the bot (/pause, /skip and /status) from the allowed users
+type Telegram struct {
+	Token string
+	Chat  int64
+	Users map[int64]bool // Users allowed to send commands
+
+	client *http.Client
+	mutex  sync.Mutex
+	last   Event // Latest event, to answer /status
+}
+
+// NewTelegram creates the integration, parsing users as a comma-separated
+// list of user ids (the chat id when empty, as for private chats)
+func NewTelegram(token string, chat int64, users string) (*Telegram, error) {
+	telegram := &Telegram{
+		Token:  token,
+		Chat:   chat,
+		Users:  make(map[int64]bool),
+		client: &http.Client{Timeout: TelegramPollTimeout + IntegrationTimeout},
+	}
+	if users == "" {
+		telegram.Users[chat] = true
+	}
+	for _, user := range strings.Split(users, ",") {
+		if user = strings.TrimSpace(user); user == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(user, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid telegram user id %q", user)
+		}
+		telegram.Users[id] = true
+	}
+	return telegram, nil
+}
+
+// Name returns the name of the integration
+func (telegram *Telegram) Name() string {
+	return "telegram"
+}
+
+// Handle sends a message about the state changes
+func (telegram *Telegram) Handle(event Event) error {
+	telegram.mutex.Lock()
+	telegram.last = event
+	telegram.mutex.Unlock()
+
+	text := TransitionMessage(event)
+	if text == "" {
+		return nil
+	}
+	return telegram.send(text)
+}
+
+// Poll receives the messages sent to the bot and forwards the commands of
+// the allowed users to the daemon
+func (telegram *Telegram) Poll(commands chan Command) {
+	var offset int64
+	for {
+		var updates []struct {
+			ID      int64 `json:"update_id"`
+			Message *struct {
+				From struct {
+					ID int64 `json:"id"`
+				} `json:"from"`
+				Chat struct {
+					ID int64 `json:"id"`
+				} `json:"chat"`
+				Text string `json:"text"`
+			} `json:"message"`
+		}
+		body := map[string]any{"offset": offset, "timeout": int(TelegramPollTimeout.Seconds())}
+		if err := telegram.call("getUpdates", body, &updates); err != nil {
+			slog.Warn("polling telegram", "err", err)
+			time.Sleep(FollowRetry)
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.ID + 1
+			message := update.Message
+			if message == nil || !telegram.Users[message.From.ID] {
+				continue
+			}
+			fields := strings.Fields(message.Text)
+			if len(fields) == 0 {
+				continue
+			}
+			// Commands may be suffixed by the bot name in groups, e.g. /pause@pomo_bot
+			name, _, _ := strings.Cut(fields[0], "@")
+			if name == "/status" {
+				if err := telegram.send(telegram.status()); err != nil {
+					slog.Warn("answering telegram", "err", err)
+				}
+			} else if command, ok := TelegramCommands[name]; ok {
+				commands <- Command{Name: command}
+			}
+		}
+	}
+}
+
+// status describes the state after the latest event
+func (telegram *Telegram) status() string {
+	telegram.mutex.Lock()
+	defer telegram.mutex.Unlock()
+
+	remaining := telegram.last.Remaining
+	if !telegram.last.Paused {
+		remaining -= time.Since(telegram.last.Time)
+	}
+	text := T("%s, %s left", telegram.last.Label(), formatClock(remaining))
+	if telegram.last.Task != "" {
+		text = T("%s on %s", text, telegram.last.Task)
+	}
+	return text
+}
+
+func (telegram *Telegram) send(text string) error {
+	return telegram.call("sendMessage", map[string]any{"chat_id": telegram.Chat, "text": text}, nil)
+}
+
+// call calls a method of the Bot API, decoding its result
+func (telegram *Telegram) call(method string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	response, err := telegram.client.Post(TelegramAPI+telegram.Token+"/"+method, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// The error contains the URL, hence the token
+		return fmt.Errorf("calling %s: %s", method, strings.ReplaceAll(err.Error(), telegram.Token, "<token>"))
+	}
+	defer response.Body.Close()
+
+	var reply struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("calling %s: %w", method, err)
+	}
+	if !reply.OK {
+		return fmt.Errorf("calling %s: %s", method, reply.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTheme is the name of the theme used unless -theme is set
+const DefaultTheme = "default"
+
+// Theme is a named look of the output: icons, markup colours and labels by
+// class (work, rest or paused), and the remaining time from which the end of
+// a period is highlighted with the "ending" colour. The -work-ramp,
+// -rest-ramp, -markup-colors and -labels settings override it.
+type Theme struct {
+	Name   string
+	Icons  map[string]string
+	Ramps  map[PomodoroStatus][]string
+	Colors map[string]string
+	Labels map[string]string
+	Ending time.Duration // 0 to never highlight the end of a period
+}
+
+// Themes are the preset themes, besides the ones of the themes directory
+var Themes = map[string]Theme{
+	DefaultTheme: {
+		Colors: ParseMapping(DefaultMarkupColors),
+	},
+	"nerd": {
+		Icons:  map[string]string{"work": "\uf017", "rest": "\uf0f4", "paused": "\uf04c"}, // Nerd Fonts clock, coffee and pause
+		Colors: ParseMapping(DefaultMarkupColors),
+	},
+	"ascii": {
+		Icons:  map[string]string{"work": "W", "rest": "R", "paused": "P"},
+		Labels: map[string]string{"work": "focus", "rest": "break", "paused": "paused"},
+	},
+	"moon": {
+		Ramps:  map[PomodoroStatus][]string{Work: {"🌑", "🌒", "🌓", "🌔", "🌕"}, Rest: {"🌕", "🌖", "🌗", "🌘", "🌑"}},
+		Colors: map[string]string{"work": "#c0caf5", "rest": "#7aa2f7", "paused": "#565f89"},
+	},
+	// Okabe-Ito colours, told apart with red-green colour blindness
+	"colorblind": {
+		Colors: map[string]string{"work": "#e69f00", "rest": "#56b4e9", "paused": "#f0e442", "ending": "#d55e00"},
+		Ending: 2 * time.Minute,
+	},
+	"high-contrast": {
+		Colors: map[string]string{"work": "#ffffff", "rest": "#00ffff", "paused": "#ffff00", "ending": "#ff0000"},
+		Ending: 2 * time.Minute,
+	},
+}
+
+// ThemeNames returns the names of the preset themes
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadTheme returns the preset theme with the given name, or else reads the
+// theme file dir/name, made of "key = value" lines like the config file. The
+// keys are work-icon, rest-icon, paused-icon, work-ramp, rest-ramp,
+// markup-colors, labels and ending, and the theme extends the default one.
+func LoadTheme(dir, name string) (Theme, error) {
+	if theme, ok := Themes[name]; ok {
+		theme.Name = name
+		return theme, nil
+	}
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return Theme{}, fmt.Errorf("invalid theme name %q", name)
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	icons := make(map[string]*string)
+	for _, class := range []string{"work", "rest", "paused"} {
+		icons[class] = flags.String(class+"-icon", "", "")
+	}
+	workRamp := flags.String("work-ramp", "", "")
+	restRamp := flags.String("rest-ramp", "", "")
+	colors := flags.String("markup-colors", DefaultMarkupColors, "")
+	labels := flags.String("labels", "", "")
+	var ending DurationFlag
+	flags.Var(&ending, "ending", "")
+	if err := LoadConfig(flags, filepath.Join(dir, name), nil); errors.Is(err, os.ErrNotExist) {
+		return Theme{}, fmt.Errorf("unknown theme %q (expected %s or a file in %s)", name, strings.Join(ThemeNames(), ", "), dir)
+	} else if err != nil {
+		return Theme{}, fmt.Errorf("loading theme %s: %w", name, err)
+	}
+
+	theme := Theme{
+		Name:   name,
+		Icons:  make(map[string]string),
+		Ramps:  make(map[PomodoroStatus][]string),
+		Colors: ParseMapping(*colors),
+		Labels: ParseMapping(*labels),
+		Ending: time.Duration(ending),
+	}
+	for class, icon := range icons {
+		if *icon != "" {
+			theme.Icons[class] = *icon
+		}
+	}
+	if *workRamp != "" {
+		theme.Ramps[Work] = strings.Split(*workRamp, ",")
+	}
+	if *restRamp != "" {
+		theme.Ramps[Rest] = strings.Split(*restRamp, ",")
+	}
+	return theme, nil
+}
+
+// overlay returns the entries of base replaced or completed by the ones of top
+func overlay[K comparable, V any](base, top map[K]V) map[K]V {
+	merged := make(map[K]V, len(base)+len(top))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range top {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Ending returns whether the running period is in its highlighted end, per the theme
+func (state *PomodoroState) Ending() bool {
+	return state.Theme.Ending > 0 && !state.Paused && state.Remaining() <= state.Theme.Ending
+}
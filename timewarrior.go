@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// timewTime is the date format understood by timew
+const timewTime = "2006-01-02T15:04:05"
+
+// Timewarrior tracks the running work periods in Timewarrior by calling timew,
+// tagged with "pomodoro", the extra tags and the task label
+type Timewarrior struct {
+	Tags []string
+
+	running bool
+}
+
+// Name returns the name of the integration
+func (timew *Timewarrior) Name() string {
+	return "timewarrior"
+}
+
+// Handle stops tracking when the work period stops running (or the task
+// changes) and starts tracking when a work period starts running
+func (timew *Timewarrior) Handle(event Event) error {
+	running := event.Running(Work)
+	if timew.running && (!running || event.Name == EventTask || event.Name == EventWorkEnd) {
+		timew.running = false
+		if err := timew.run("stop", event.Time.Format(timewTime)); err != nil {
+			return err
+		}
+	}
+
+	if running && !timew.running {
+		args := []string{"start", event.Time.Format(timewTime), "pomodoro"}
+		args = append(args, timew.Tags...)
+		if event.Task != "" {
+			args = append(args, event.Task)
+		}
+		if err := timew.run(args...); err != nil {
+			return err
+		}
+		timew.running = true
+	}
+	return nil
+}
+
+func (timew *Timewarrior) run(args ...string) error {
+	output, err := exec.Command("timew", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("timew %s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
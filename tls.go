@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSFiles are the PEM files of the TLS settings, shared by the network
+// listeners of the daemon and the clients dialing tls:// addresses
+type TLSFiles struct {
+	Cert, Key string // Certificate and key of this host, presented to clients and to daemons
+	CA        string // CA certificates verifying the peers, which makes the listeners require client certificates
+}
+
+// TLS are the TLS settings of the -tls-cert, -tls-key and -tls-ca flags
+var TLS TLSFiles
+
+// ServerConfig returns the TLS config of the network listeners, nil when no
+// certificate is set. With a CA, clients must present a certificate it signed.
+func (files TLSFiles) ServerConfig() (*tls.Config, error) {
+	if files.Cert == "" && files.Key == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if files.CA != "" {
+		if config.ClientCAs, err = loadCertPool(files.CA); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientConfig returns the TLS config dialing a daemon on host, verified
+// against the CA when set or else the system roots
+func (files TLSFiles) ClientConfig(host string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	var err error
+	if files.CA != "" {
+		if config.RootCAs, err = loadCertPool(files.CA); err != nil {
+			return nil, err
+		}
+	}
+	if files.Cert != "" || files.Key != "" {
+		cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// loadCertPool reads the PEM certificates of a file
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TogglAPI is the base URL of the Toggl Track API
+const TogglAPI = "https://api.track.toggl.com/api/v9"
+
+// Toggl tracks the running work periods as Toggl Track time entries, described
+// by the task label. Entries that can't be sent while the API is unreachable
+// are queued (and saved to disk) until the next event.
+type Toggl struct {
+	Token     string
+	Workspace int64
+	Projects  map[string]int64 // Project id by task label, "*" matching any other task
+	QueuePath string
+
+	client  *http.Client
+	current *togglEntry  // Entry of the running work period
+	queue   []togglEntry // Finished entries not sent yet
+}
+
+// togglEntry is a time entry, created on Toggl (with an ID) or only known locally
+type togglEntry struct {
+	ID          int64     `json:"id,omitempty"`
+	Description string    `json:"description"`
+	ProjectID   int64     `json:"project_id,omitempty"`
+	Start       time.Time `json:"start"`
+	Stop        time.Time `json:"stop"`
+}
+
+// NewToggl creates the integration, parsing projects as a comma-separated list
+// of label=id pairs, and loads the entries queued by a previous run
+func NewToggl(token string, workspace int64, projects string) (*Toggl, error) {
+	toggl := &Toggl{
+		Token:     token,
+		Workspace: workspace,
+		Projects:  make(map[string]int64),
+		QueuePath: DataDir() + "/toggl-queue.json",
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for label, value := range ParseMapping(projects) {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid toggl project id %q for %q", value, label)
+		}
+		toggl.Projects[label] = id
+	}
+
+	if data, err := os.ReadFile(toggl.QueuePath); err == nil {
+		if err := json.Unmarshal(data, &toggl.queue); err != nil {
+			return nil, fmt.Errorf("invalid toggl queue %s: %w", toggl.QueuePath, err)
+		}
+	}
+	return toggl, nil
+}
+
+// Name returns the name of the integration
+func (toggl *Toggl) Name() string {
+	return "toggl"
+}
+
+// Handle stops the time entry when the work period stops running (or the task
+// changes) and starts a new one when a work period starts running
+func (toggl *Toggl) Handle(event Event) error {
+	running := event.Running(Work)
+	if toggl.current != nil && (!running || event.Name == EventTask || event.Name == EventWorkEnd) {
+		toggl.current.Stop = event.Time
+		toggl.queue = append(toggl.queue, *toggl.current)
+		toggl.current = nil
+	}
+
+	var err error
+	if running && toggl.current == nil {
+		toggl.current = &togglEntry{
+			Description: event.Task,
+			ProjectID:   toggl.project(event.Task),
+			Start:       event.Time,
+		}
+		if toggl.current.Description == "" {
+			toggl.current.Description = "Pomodoro"
+		}
+		// Without an ID the entry is created as a finished one when it stops
+		toggl.current.ID, err = toggl.create(*toggl.current)
+	}
+	return errors.Join(err, toggl.flush())
+}
+
+// flush sends the queued entries, keeping the ones that failed
+func (toggl *Toggl) flush() error {
+	if len(toggl.queue) == 0 {
+		return nil
+	}
+
+	var failed []togglEntry
+	var errs error
+	for _, entry := range toggl.queue {
+		var err error
+		if entry.ID != 0 {
+			err = toggl.stop(entry)
+		} else {
+			_, err = toggl.create(entry)
+		}
+		if err != nil {
+			failed = append(failed, entry)
+			errs = err
+		}
+	}
+	toggl.queue = failed
+
+	data, err := json.Marshal(toggl.queue)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(DataDir(), 0o700); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(toggl.QueuePath, string(data)); err != nil {
+		return err
+	}
+	if errs != nil {
+		return fmt.Errorf("%d entries queued: %w", len(failed), errs)
+	}
+	return nil
+}
+
+func (toggl *Toggl) project(task string) int64 {
+	if id, ok := toggl.Projects[task]; ok {
+		return id
+	}
+	return toggl.Projects["*"]
+}
+
+// create creates a running entry, or a finished one when it has a stop time
+func (toggl *Toggl) create(entry togglEntry) (int64, error) {
+	body := map[string]any{
+		"created_with": "polybar-pomo",
+		"workspace_id": toggl.Workspace,
+		"description":  entry.Description,
+		"start":        entry.Start.UTC().Format(time.RFC3339),
+		"duration":     -1,
+	}
+	if entry.ProjectID != 0 {
+		body["project_id"] = entry.ProjectID
+	}
+	if !entry.Stop.IsZero() {
+		body["stop"] = entry.Stop.UTC().Format(time.RFC3339)
+		body["duration"] = int64(entry.Stop.Sub(entry.Start).Seconds())
+	}
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	path := fmt.Sprintf("/workspaces/%d/time_entries", toggl.Workspace)
+	err := toggl.request(http.MethodPost, path, body, &created)
+	return created.ID, err
+}
+
+// stop sets the stop time of an entry created while running
+func (toggl *Toggl) stop(entry togglEntry) error {
+	body := map[string]any{"stop": entry.Stop.UTC().Format(time.RFC3339)}
+	path := fmt.Sprintf("/workspaces/%d/time_entries/%d", toggl.Workspace, entry.ID)
+	return toggl.request(http.MethodPut, path, body, nil)
+}
+
+func (toggl *Toggl) request(method, path string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(method, TogglAPI+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.SetBasicAuth(toggl.Token, "api_token")
+
+	response, err := toggl.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, path, response.Status, strings.TrimSpace(string(message)))
+	}
+	if result != nil {
+		return json.NewDecoder(response.Body).Decode(result)
+	}
+	return nil
+}
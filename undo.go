@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// UndoDepth is the number of commands that can be undone in a row
+const UndoDepth = 10
+
+// Undoable lists the commands changing the timer, which undo can revert
+var Undoable = map[string]bool{
+	"pause": true, "toggle": true, "skip": true, "restart": true, "abandon": true, "inc": true,
+	"dec": true, "snooze": true, "interrupt": true, "note": true, "task": true, "estimate": true,
+}
+
+// Checkpoint is a copy of the timer state taken before a command, restored by undo
+type Checkpoint struct {
+	Command   string
+	Time      time.Time
+	Status    PomodoroStatus
+	Paused    bool
+	Remaining time.Duration
+
+	Started   time.Time
+	PausedAt  time.Time
+	PausedFor time.Duration
+	Extended  time.Duration
+	Ended     bool
+
+	Interruptions []string
+	Notes         []string
+	Task          string
+	Estimate      int
+
+	LastRecorded time.Time
+	Today        int
+	TodayDate    string
+}
+
+// Checkpoint copies the state before the command is applied
+func (state *PomodoroState) Checkpoint(cmd Command) Checkpoint {
+	return Checkpoint{
+		Command:   cmd.String(),
+		Time:      time.Now(),
+		Status:    state.Status,
+		Paused:    state.Paused,
+		Remaining: state.Remaining(),
+
+		Started:   state.Started,
+		PausedAt:  state.PausedAt,
+		PausedFor: state.PausedFor,
+		Extended:  state.Extended,
+		Ended:     state.Ended,
+
+		Interruptions: state.Interruptions,
+		Notes:         state.Notes,
+		Task:          state.Task,
+		Estimate:      state.Estimates[state.Task],
+
+		LastRecorded: state.LastRecorded,
+		Today:        state.Today,
+		TodayDate:    state.TodayDate,
+	}
+}
+
+// pushUndo keeps the checkpoint, forgetting the oldest ones past UndoDepth
+func (state *PomodoroState) pushUndo(checkpoint Checkpoint) {
+	state.UndoStack = append(state.UndoStack, checkpoint)
+	if len(state.UndoStack) > UndoDepth {
+		state.UndoStack = state.UndoStack[len(state.UndoStack)-UndoDepth:]
+	}
+}
+
+// Undo reverts the last command as if it was never sent: a timer that was
+// running keeps the time elapsed since, and a period recorded by the command
+// is removed from the history
+func (state *PomodoroState) Undo() (string, error) {
+	if len(state.UndoStack) == 0 {
+		return "", errors.New("nothing to undo")
+	}
+	checkpoint := state.UndoStack[len(state.UndoStack)-1]
+	state.UndoStack = state.UndoStack[:len(state.UndoStack)-1]
+	if err := state.Restore(checkpoint); err != nil {
+		return "", err
+	}
+	return checkpoint.Command, nil
+}
+
+// Restore puts the timer back as it was at the checkpoint, removing the period
+// recorded since from the history
+func (state *PomodoroState) Restore(checkpoint Checkpoint) error {
+	if state.History != nil && !state.LastRecorded.Equal(checkpoint.LastRecorded) {
+		if err := state.persist(state.History.DropLast); err != nil {
+			return err
+		}
+	}
+
+	remaining := checkpoint.Remaining
+	if !checkpoint.Paused {
+		remaining -= time.Since(checkpoint.Time)
+	}
+	state.Status, state.Paused = checkpoint.Status, checkpoint.Paused
+	state.setEnd(remaining.Round(time.Second))
+	if state.Paused {
+		state.Timer.Stop()
+	} else {
+		state.Timer.Reset(remaining)
+	}
+
+	state.Started, state.PausedAt, state.PausedFor = checkpoint.Started, checkpoint.PausedAt, checkpoint.PausedFor
+	state.Extended, state.Ended = checkpoint.Extended, checkpoint.Ended
+	state.Interruptions, state.Notes = checkpoint.Interruptions, checkpoint.Notes
+	state.Task = checkpoint.Task
+	if checkpoint.Estimate > 0 {
+		state.Estimates[state.Task] = checkpoint.Estimate
+	} else {
+		delete(state.Estimates, state.Task)
+	}
+	state.LastRecorded, state.Today, state.TodayDate = checkpoint.LastRecorded, checkpoint.Today, checkpoint.TodayDate
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Display views of the module, cycled through with the view command
+const (
+	ViewCountdown = "countdown" // Time remaining in the period
+	ViewCountUp   = "countup"   // Time elapsed since the start of the period
+	ViewEnd       = "end"       // Time of day the period ends at
+	ViewToday     = "today"     // Work periods completed today
+)
+
+// Renderings of the paused state
+const (
+	PausedFrozen  = "frozen"  // The remaining time, frozen
+	PausedBlink   = "blink"   // The remaining time, with a blinking icon
+	PausedCounter = "counter" // The time spent paused, e.g. "paused 03:12"
+	PausedHidden  = "hidden"  // Nothing, hiding the module
+)
+
+// DefaultViews are the views cycled through when none are configured
+const DefaultViews = ViewCountdown + "," + ViewCountUp + "," + ViewEnd
+
+// ParseViews parses a comma-separated list of views
+func ParseViews(list string) ([]string, error) {
+	var views []string
+	for _, view := range strings.Split(list, ",") {
+		view = strings.TrimSpace(view)
+		switch view {
+		case ViewCountdown, ViewCountUp, ViewEnd, ViewToday:
+			views = append(views, view)
+		default:
+			return nil, fmt.Errorf("unknown view %q (expected countdown, countup, end or today)", view)
+		}
+	}
+	return views, nil
+}
+
+// CurrentView returns the view being displayed
+func (state *PomodoroState) CurrentView() string {
+	if len(state.Views) == 0 {
+		return ViewCountdown
+	}
+	return state.Views[state.View%len(state.Views)]
+}
+
+// SetView displays the next configured view, or the one with the given name
+func (state *PomodoroState) SetView(name string) error {
+	if name == "next" || name == "" {
+		state.View = (state.View + 1) % max(1, len(state.Views))
+		return nil
+	}
+	for i, view := range state.Views {
+		if view == name {
+			state.View = i
+			return nil
+		}
+	}
+	return fmt.Errorf("view %q is not configured", name)
+}
+
+// Clock formats the time shown by the current view
+func (state *PomodoroState) Clock() string {
+	if state.Paused && state.PausedDisplay == PausedCounter {
+		return Label("paused") + " " + formatClock(time.Since(state.PausedAt))
+	}
+
+	switch state.CurrentView() {
+	case ViewCountUp:
+		elapsed := GetDuration(state.Status) - state.Remaining()
+		if state.MinutesOnly {
+			return "↑" + formatMinutes(elapsed.Truncate(time.Minute))
+		}
+		return "↑" + formatClock(elapsed)
+	case ViewEnd:
+		return "→ " + time.Now().Add(state.Remaining()).Format("15:04")
+	case ViewToday:
+		return T("%d✓ today", state.CompletedToday())
+	}
+
+	remaining := state.Remaining()
+	if state.MinutesOnly {
+		// Started minutes count, so the display reaches 0m when the period ends
+		if remaining <= -time.Minute {
+			return "+" + formatMinutes((-remaining).Truncate(time.Minute))
+		}
+		return formatMinutes(max(0, remaining+time.Minute-time.Nanosecond).Truncate(time.Minute))
+	}
+	if remaining <= -time.Second/2 {
+		// Overtime is displayed as the time elapsed since the end of the period
+		return "+" + formatClock(-remaining)
+	}
+	return formatClock(remaining)
+}
+
+// formatMinutes formats a whole number of minutes, e.g. 25m
+func formatMinutes(duration time.Duration) string {
+	return fmt.Sprintf("%dm", int(duration.Minutes()))
+}
+
+// formatClock formats a duration as MM:SS
+func formatClock(duration time.Duration) string {
+	duration = max(0, duration.Round(time.Second))
+	minutes := int(duration.Minutes())
+	seconds := int(duration.Seconds()) - 60*minutes
+	return fmt.Sprintf("%02d:%02d", minutes, seconds)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultWebhookEvents are the events posted to webhooks unless configured otherwise
+const DefaultWebhookEvents = EventWorkStart + "," + EventRestStart + "," + EventPause + "," + EventResume
+
+// WebhookRetryDelay is the delay before the first retry of a failed webhook, doubled after each retry
+const WebhookRetryDelay = time.Second
+
+// Webhook posts a JSON payload to URLs on the selected events. The body is the
+// event as a JSON object, unless a text/template is configured.
+type Webhook struct {
+	URLs     []string
+	Events   map[string]bool
+	Template *template.Template
+	Retries  int
+
+	client *http.Client
+}
+
+// webhookPayload is the default body of webhooks, also available to templates
+type webhookPayload struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Status    string    `json:"status"`
+	Label     string    `json:"label"`
+	Paused    bool      `json:"paused"`
+	Remaining int       `json:"remaining"` // Seconds
+	Task      string    `json:"task"`
+}
+
+// NewWebhook creates the integration posting the events of the comma-separated
+// list to the comma-separated urls, with the body template if not empty
+func NewWebhook(urls, events, body string, timeout time.Duration, retries int) (*Webhook, error) {
+	webhook := &Webhook{
+		URLs:    strings.Split(urls, ","),
+		Events:  make(map[string]bool),
+		Retries: retries,
+		client:  &http.Client{Timeout: timeout},
+	}
+	for _, event := range strings.Split(events, ",") {
+		webhook.Events[strings.TrimSpace(event)] = true
+	}
+	if body != "" {
+		// The json function encodes a value, e.g. {"text": {{json .Task}}}
+		functions := template.FuncMap{"json": func(value any) (string, error) {
+			data, err := json.Marshal(value)
+			return string(data), err
+		}}
+		var err error
+		if webhook.Template, err = template.New("webhook").Funcs(functions).Parse(body); err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+	}
+	return webhook, nil
+}
+
+// Name returns the name of the integration
+func (webhook *Webhook) Name() string {
+	return "webhook"
+}
+
+// Handle posts the event to every URL, retrying with exponential backoff
+func (webhook *Webhook) Handle(event Event) error {
+	if !webhook.Events[event.Name] {
+		return nil
+	}
+
+	payload := webhookPayload{
+		Event:     event.Name,
+		Time:      event.Time,
+		Status:    event.Status.String(),
+		Label:     event.Label(),
+		Paused:    event.Paused,
+		Remaining: int(event.Remaining.Round(time.Second).Seconds()),
+		Task:      event.Task,
+	}
+	var body bytes.Buffer
+	if webhook.Template != nil {
+		if err := webhook.Template.Execute(&body, payload); err != nil {
+			return err
+		}
+	} else if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		return err
+	}
+
+	var err error
+	for _, url := range webhook.URLs {
+		delay := WebhookRetryDelay
+		for attempt := 0; attempt <= webhook.Retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(delay)
+				delay *= 2
+			}
+			if err = webhook.post(url, body.Bytes()); err == nil {
+				break
+			}
+		}
+	}
+	return err
+}
+
+func (webhook *Webhook) post(url string, body []byte) error {
+	response, err := webhook.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", url, response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Workspaces switches the workspaces of the window manager and focuses windows
+type Workspaces interface {
+	Current() (string, error)
+	Switch(workspace string) error
+	Show(class string) error
+}
+
+// NewWorkspaces returns the workspace backend of a window manager: sway, i3,
+// hyprland, wmctrl for the other X11 window managers, or auto to pick the
+// running one
+func NewWorkspaces(name string) (Workspaces, error) {
+	if name == "auto" {
+		switch {
+		case os.Getenv("SWAYSOCK") != "":
+			name = "sway"
+		case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+			name = "hyprland"
+		case os.Getenv("I3SOCK") != "":
+			name = "i3"
+		default:
+			name = "wmctrl"
+		}
+	}
+	switch name {
+	case "sway":
+		return Sway{}, nil
+	case "i3":
+		return I3{}, nil
+	case "hyprland":
+		return HyprlandWorkspaces{}, nil
+	case "wmctrl":
+		return Wmctrl{}, nil
+	}
+	return nil, fmt.Errorf("unknown window manager %q (expected auto, sway, i3, hyprland or wmctrl)", name)
+}
+
+// BreakWorkspace switches to a workspace, or shows the window of an
+// application, when a break starts, and switches back to the workspace of
+// the work period when work resumes (or on exit)
+type BreakWorkspace struct {
+	WM        Workspaces
+	Workspace string // Workspace of the breaks, none to stay on the current one
+	Window    string // Class of the window shown on break, none to show no window
+
+	away     bool
+	previous string // Workspace before the break
+}
+
+// Name returns the name of the integration
+func (workspace *BreakWorkspace) Name() string {
+	return "workspace"
+}
+
+// Handle leaves for the break workspace on a running rest period, and comes
+// back on work or shutdown
+func (workspace *BreakWorkspace) Handle(event Event) error {
+	if event.Running(Rest) && !workspace.away {
+		workspace.away = true
+		return workspace.leave()
+	} else if workspace.away && (event.Status == Work || event.Name == EventShutdown) {
+		workspace.away = false
+		if workspace.previous == "" {
+			return nil
+		}
+		return workspace.WM.Switch(workspace.previous)
+	}
+	return nil
+}
+
+func (workspace *BreakWorkspace) leave() error {
+	current, err := workspace.WM.Current()
+	if err != nil {
+		return err
+	}
+	workspace.previous = current
+	if workspace.Workspace != "" {
+		if err := workspace.WM.Switch(workspace.Workspace); err != nil {
+			return err
+		}
+	}
+	if workspace.Window != "" {
+		return workspace.WM.Show(workspace.Window)
+	}
+	return nil
+}
+
+// showMatching focuses the first window of a window manager with the class
+func showMatching(wm WindowManager, class string, focus func(Window) error) error {
+	windows, err := wm.Windows()
+	if err != nil {
+		return err
+	}
+	for _, window := range windows {
+		if matchClass(window.Classes, []string{class}) {
+			return focus(window)
+		}
+	}
+	return fmt.Errorf("no window of class %q", class)
+}
+
+// i3Workspace is a workspace of the get_workspaces reply of sway and i3
+type i3Workspace struct {
+	Name    string `json:"name"`
+	Focused bool   `json:"focused"`
+}
+
+// focusedWorkspace returns the name of the focused workspace, through swaymsg or i3-msg
+func focusedWorkspace(program string) (string, error) {
+	output, err := exec.Command(program, "-t", "get_workspaces").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s -t get_workspaces: %w", program, err)
+	}
+	var workspaces []i3Workspace
+	if err := json.Unmarshal(output, &workspaces); err != nil {
+		return "", err
+	}
+	for _, workspace := range workspaces {
+		if workspace.Focused {
+			return workspace.Name, nil
+		}
+	}
+	return "", errors.New("no focused workspace")
+}
+
+// Current returns the name of the focused workspace
+func (Sway) Current() (string, error) {
+	return focusedWorkspace("swaymsg")
+}
+
+// Switch focuses a workspace by name
+func (Sway) Switch(workspace string) error {
+	return i3msg("swaymsg", "workspace "+strconv.Quote(workspace))
+}
+
+// Show focuses the first window of an application, by Wayland app id or X11 class
+func (sway Sway) Show(class string) error {
+	return showMatching(sway, class, func(window Window) error {
+		return swaymsg(window, "focus")
+	})
+}
+
+// I3 switches the workspaces of i3 with i3-msg
+type I3 struct{}
+
+// Current returns the name of the focused workspace
+func (I3) Current() (string, error) {
+	return focusedWorkspace("i3-msg")
+}
+
+// Switch focuses a workspace by name
+func (I3) Switch(workspace string) error {
+	return i3msg("i3-msg", "workspace "+strconv.Quote(workspace))
+}
+
+// Show focuses the first window of an application, by X11 class in any case
+func (I3) Show(class string) error {
+	return i3msg("i3-msg", fmt.Sprintf("[class=%q] focus", "(?i)^"+regexp.QuoteMeta(class)+"$"))
+}
+
+func i3msg(program, command string) error {
+	if output, err := exec.Command(program, command).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %w: %s", program, command, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// HyprlandWorkspaces switches the workspaces of Hyprland through its socket
+type HyprlandWorkspaces struct{}
+
+// Current returns the id of the active workspace
+func (HyprlandWorkspaces) Current() (string, error) {
+	reply, err := Hyprctl("j/activeworkspace")
+	if err != nil {
+		return "", err
+	}
+	var workspace struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(reply, &workspace); err != nil {
+		return "", fmt.Errorf("hyprland: %s", bytes.TrimSpace(reply))
+	}
+	return strconv.Itoa(workspace.ID), nil
+}
+
+// Switch focuses a workspace, by id or as "name:break"
+func (HyprlandWorkspaces) Switch(workspace string) error {
+	return HyprctlBatch("dispatch workspace " + workspace)
+}
+
+// Show focuses the first window of an application, by class in any case
+func (HyprlandWorkspaces) Show(class string) error {
+	return HyprctlBatch("dispatch focuswindow class:(?i)^" + regexp.QuoteMeta(class) + "$")
+}
+
+// Current returns the number of the current desktop
+func (Wmctrl) Current() (string, error) {
+	output, err := exec.Command("wmctrl", "-d").Output()
+	if err != nil {
+		return "", fmt.Errorf("wmctrl -d: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// e.g. 0  * DG: 1920x1080  VP: 0,0  WA: 0,0 1920x1080  1
+		if fields := strings.Fields(scanner.Text()); len(fields) > 1 && fields[1] == "*" {
+			return fields[0], nil
+		}
+	}
+	return "", errors.New("wmctrl: no current desktop")
+}
+
+// Switch switches to a desktop by number, counted from 0
+func (Wmctrl) Switch(workspace string) error {
+	return wmctrl("-s", workspace)
+}
+
+// Show activates the first window of an application, switching to its desktop
+func (wm Wmctrl) Show(class string) error {
+	return showMatching(wm, class, func(window Window) error {
+		return wmctrl("-ia", window.ID)
+	})
+}